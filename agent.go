@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marko-gacesa/fenc/internal/agent"
+	"github.com/marko-gacesa/fenc/internal/password"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+func runAgent(args []string) {
+	cmd, _ := findCommand("agent")
+	fs := newFlagSet(cmd)
+	socket := fs.String("socket", "", "Path of the agent socket. For a new agent, it's created in a private temporary directory by default.\nOtherwise, it's taken from "+agent.EnvSocket+".")
+	ttl := fs.Duration("ttl", time.Hour, "How long the keys are kept. For a new agent it's the default, with -add it applies to the added key.")
+	foreground := fs.Bool("foreground", false, "Run the new agent in the foreground.")
+	add := fs.Bool("add", false, "Ask for the key phrase and store its key in the running agent.")
+	allowWeak := fs.Bool("u", false, "Insecure. Allow weak key phrase with -add.")
+	minStrength := fs.Int("min-strength", password.DefaultMinScore, "Minimum strength score of the key phrase with -add, from 0 to 4.")
+	lock := fs.Bool("lock", false, "Lock the running agent with a passphrase. It refuses requests for keys until unlocked.")
+	unlock := fs.Bool("unlock", false, "Unlock the running agent.")
+	clearKeys := fs.Bool("clear", false, "Remove all keys from the running agent.")
+	kill := fs.Bool("kill", false, "Stop the running agent.")
+	_ = fs.Parse(args)
+
+	flagsSet := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		flagsSet[f.Name] = true
+	})
+
+	actions := 0
+	for _, set := range []bool{*add, *lock, *unlock, *clearKeys, *kill} {
+		if set {
+			actions++
+		}
+	}
+
+	if fs.NArg() != 0 || actions > 1 || *ttl < time.Second {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	if actions == 0 {
+		if *foreground {
+			serveAgent(*socket, *ttl)
+		} else {
+			startAgent(*socket, *ttl)
+		}
+		return
+	}
+
+	if *socket == "" {
+		*socket = os.Getenv(agent.EnvSocket)
+		if *socket == "" {
+			fatalf("Agent error: %s is not set", agent.EnvSocket)
+		}
+	}
+
+	var err error
+
+	switch {
+	case *add:
+		var keyPhrase []byte
+
+		minScore := *minStrength
+		if *allowWeak {
+			minScore = password.NoStrengthCheck
+		}
+
+		keyPhrase, err = password.InputWithPrompt("Enter key phrase", minScore, true)
+		if err != nil {
+			fatalf("Key phrase error: %s", err.Error())
+		}
+
+		var keyTTL time.Duration
+		if flagsSet["ttl"] {
+			keyTTL = *ttl
+		}
+
+		// The agent holds only the key made from the normalized key phrase. Files encrypted by older versions
+		// with the key phrase composed differently need the key phrase entered directly, or rekey.
+		// The normalized key phrase and the key can be copies, all of them are wiped.
+		normalized := processor.Normalize(keyPhrase)
+		if !bytes.Equal(normalized, keyPhrase) {
+			log.Println("Warning: The key phrase is normalized to Unicode NFC. Files encrypted by older versions")
+			log.Println("with the key phrase as entered can't be decrypted with the key from the agent.")
+		}
+
+		phraseKey := processor.Key(normalized)
+		key := secure.LockedCopy(phraseKey)
+		clear(phraseKey)
+		clear(normalized)
+		secure.Wipe(keyPhrase)
+
+		err = agent.Add(*socket, agent.DefaultKeyName, key, keyTTL)
+		secure.Wipe(key)
+
+	case *lock, *unlock:
+		var passphrase []byte
+
+		passphrase, err = password.InputWithPrompt("Enter lock passphrase", password.NoStrengthCheck, *lock)
+		if err != nil {
+			fatalf("Passphrase error: %s", err.Error())
+		}
+
+		if *lock {
+			err = agent.Lock(*socket, passphrase)
+		} else {
+			err = agent.Unlock(*socket, passphrase)
+		}
+
+		secure.Wipe(passphrase)
+
+	case *clearKeys:
+		err = agent.Clear(*socket)
+
+	case *kill:
+		err = agent.Stop(*socket)
+	}
+
+	if err != nil {
+		fatalf("Agent error: %s", err.Error())
+	}
+}
+
+// startAgent starts the agent in the background and prints the shell commands that set the environment variable,
+// to be used like: eval $(fenc agent)
+func startAgent(socket string, ttl time.Duration) {
+	exe, err := os.Executable()
+	if err != nil {
+		fatalf("Agent error: %s", err.Error())
+	}
+
+	c := exec.Command(exe, "agent", "-foreground", "-socket", socket, "-ttl", ttl.String())
+	c.Stderr = os.Stderr
+	agent.Detach(c)
+
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		fatalf("Agent error: %s", err.Error())
+	}
+
+	if err = c.Start(); err != nil {
+		fatalf("Agent error: failed to start: %s", err.Error())
+	}
+
+	// the agent prints the line with the environment variable once it's ready
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		fatalf("Agent error: failed to start")
+	}
+
+	fmt.Print(line)
+	fmt.Printf("echo Agent pid %d;\n", c.Process.Pid)
+
+	_ = c.Process.Release()
+}
+
+// serveAgent runs the agent until it's stopped with a signal or with the kill command.
+func serveAgent(socket string, ttl time.Duration) {
+	var dir string
+
+	if socket == "" {
+		var err error
+
+		dir, err = os.MkdirTemp("", "fenc-agent-")
+		if err != nil {
+			fatalf("Agent error: %s", err.Error())
+		}
+
+		socket = filepath.Join(dir, "agent.sock")
+	}
+
+	listener, err := agent.Listen(socket)
+	if err != nil {
+		fatalf("Agent error: %s", err.Error())
+	}
+
+	cleanup := func() {
+		_ = os.Remove(socket)
+		if dir != "" {
+			_ = os.Remove(dir)
+		}
+	}
+
+	a := agent.New(ttl)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt}, terminationSignals...)...)
+	go func() {
+		<-signals
+		a.Stop()
+	}()
+
+	fmt.Printf("%s=%s; export %s;\n", agent.EnvSocket, shellQuote(socket), agent.EnvSocket)
+
+	err = a.Serve(listener)
+
+	cleanup()
+
+	if err != nil {
+		fatalf("Agent error: %s", err.Error())
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
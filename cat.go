@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/processor"
+)
+
+func runCat(args []string) {
+	cmd, _ := findCommand("cat")
+	fs := newFlagSet(cmd)
+	keys := keyFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	var countDone, countFail int
+
+	for _, fileName := range fs.Args() {
+		if fileName == "-" {
+			err = processor.Decrypt(processor.DecryptOptions{}, block, os.Stdin, os.Stdout)
+		} else {
+			err = processor.DecryptToStdOut(processor.DecryptOptions{}, block, fileName)
+		}
+		if err != nil {
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		countDone++
+	}
+
+	exitWithCounts(countDone, countFail)
+}
+
+func runGrep(args []string) {
+	cmd, _ := findCommand("grep")
+	fs := newFlagSet(cmd)
+	keys := keyFlags(fs)
+	ignoreCase := fs.Bool("i", false, "Ignore case.")
+	fixed := fs.Bool("F", false, "Interpret the pattern as a fixed string, not as a regular expression.")
+	invert := fs.Bool("v", false, "Print lines that don't match.")
+	lineNumbers := fs.Bool("n", false, "Print line numbers.")
+	noFileNames := fs.Bool("h", false, "Don't print file names.")
+	onlyCount := fs.Bool("c", false, "Print only the number of matching lines of each file.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	pattern := fs.Arg(0)
+	if *fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fatalf("Pattern error: %s", err.Error())
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	var countMatched, countFail int
+
+	for _, fileName := range fs.Args()[1:] {
+		prefix := ""
+		if !*noFileNames {
+			prefix = fileName + ":"
+		}
+
+		count, err := grepFile(block, fileName, func(lineNum int, line []byte) {
+			if *onlyCount {
+				return
+			}
+
+			out.WriteString(prefix)
+			if *lineNumbers {
+				fmt.Fprintf(out, "%d:", lineNum)
+			}
+			out.Write(line)
+			out.WriteByte('\n')
+		}, func(line []byte) bool {
+			return re.Match(line) != *invert
+		})
+
+		if *onlyCount {
+			fmt.Fprintf(out, "%s%d\n", prefix, count)
+		}
+
+		if err != nil {
+			_ = out.Flush()
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		if count > 0 {
+			countMatched++
+		}
+	}
+
+	_ = out.Flush()
+
+	switch {
+	case countFail > 0:
+		os.Exit(exitCodeTrouble)
+	case countMatched == 0:
+		os.Exit(exitCodeNoMatch)
+	default:
+		os.Exit(exitCodeOK)
+	}
+}
+
+// grepFile decrypts the file and calls the print function for each line that matches.
+// Lines are passed without the line terminator. It returns the number of matching lines.
+func grepFile(block cipher.Block, fileName string, print func(lineNum int, line []byte), match func(line []byte) bool) (int, error) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(processor.DecryptToWriter(processor.DecryptOptions{}, block, fileName, pw))
+	}()
+
+	defer pr.Close()
+
+	reader := bufio.NewReader(pr)
+
+	var count, lineNum int
+
+	for {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// a very long line, collect it whole
+			long := append([]byte(nil), line...)
+			for errors.Is(err, bufio.ErrBufferFull) {
+				line, err = reader.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+
+		if len(line) > 0 {
+			lineNum++
+
+			line = trimLineEnd(line)
+			if match(line) {
+				count++
+				print(lineNum, line)
+			}
+		}
+
+		if err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+	}
+}
+
+func trimLineEnd(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+// command is a fenc subcommand, invoked as "fenc <name> <options> <arguments>".
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:        "keygen",
+			usage:       "-sign <key_file>",
+			description: "Generates a key pair for signing. The public key is stored with the '.pub' extension.",
+			run:         runKeygen,
+		},
+		{
+			name:        "sign",
+			usage:       "-key <private_key_file> <file_list>",
+			description: "Creates detached signatures of files. Signatures get the '.sig' extension.",
+			run:         runSign,
+		},
+		{
+			name:        "verify",
+			usage:       "-signer <public_key_file> <file_list>",
+			description: "Verifies detached signatures of files.",
+			run:         runVerify,
+		},
+		{
+			name:        "rekey",
+			usage:       "<options> <file_list>",
+			description: "Changes the key phrase of encrypted files, without writing decrypted data to disk.",
+			run:         runRekey,
+		},
+		{
+			name:        "upgrade",
+			usage:       "<options> <file_list>",
+			description: "Encrypts files in old formats or with deprecated hash functions again, in the current format, with the same key phrase.",
+			run:         runUpgrade,
+		},
+		{
+			name:        "edit",
+			usage:       "<options> <file>",
+			description: "Decrypts a file into a private temporary directory, opens it in $EDITOR and encrypts it again if it changed.",
+			run:         runEdit,
+		},
+		{
+			name:        "exec",
+			usage:       "-f <env_file> <options> -- <command> <arguments>",
+			description: "Runs the command with the variables from encrypted files in dotenv format, decrypted only in memory.",
+			run:         runExec,
+		},
+		{
+			name:        "cat",
+			usage:       "<options> <file_list>",
+			description: "Decrypts files to stdout, one after another. Files are never removed. Use - to read stdin.",
+			run:         runCat,
+		},
+		{
+			name:  "grep",
+			usage: "<options> <pattern> <file_list>",
+			description: "Decrypts files in memory and prints the lines that match the regular expression, with file names.\n" +
+				"Like grep, the exit code is 0 if a line matched, 1 if nothing matched and 2 if a file failed.",
+			run: runGrep,
+		},
+		{
+			name:        "diff",
+			usage:       "<options> <file1> <file2>",
+			description: "Shows the unified diff of two files, each of them encrypted or not, decrypted only in memory.",
+			run:         runDiff,
+		},
+		{
+			name:        "git-filter",
+			usage:       "<options> clean|smudge",
+			description: "Git filter. The clean filter encrypts stdin in the deterministic mode, the smudge filter decrypts it.",
+			run:         runGitFilter,
+		},
+		{
+			name:        "git-init",
+			usage:       "<options> <pattern_list>",
+			description: "Registers the git filter and the diff driver in .git/config and assigns them to the patterns in .gitattributes.",
+			run:         runGitInit,
+		},
+		{
+			name:        "agent",
+			usage:       "<options>",
+			description: "Starts the agent that keeps keys for other invocations, like ssh-agent: eval $(fenc agent). Keys are added with -add.",
+			run:         runAgent,
+		},
+		{
+			name:        "genpass",
+			usage:       "<options>",
+			description: "Generates a random passphrase from the embedded wordlist, or a random password, and shows its strength.",
+			run:         runGenpass,
+		},
+		{
+			name:        "split",
+			usage:       "<options> <name>",
+			description: "Generates a master key and splits it into recovery shares, any threshold of which rebuild it. Use it with -key shares:<share files>, or write it with -o.",
+			run:         runSplit,
+		},
+		{
+			name:        "combine",
+			usage:       "<options> <share_file_list>",
+			description: "Rebuilds the master key from recovery shares. Use - to read shares from stdin.",
+			run:         runCombine,
+		},
+		{
+			name:        "config",
+			usage:       "show <options>",
+			description: "Shows the effective settings from the config file, for the main command.",
+			run:         runConfig,
+		},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+// commandFromArg returns the command named by the first argument. The name of a command is always the command,
+// so a file with that name must be named as ./NAME, or follow the option --, to be processed by the main command.
+// If such a file exists, a warning tells how to process it.
+func commandFromArg(arg string) (command, bool) {
+	cmd, ok := findCommand(arg)
+	if !ok {
+		return command{}, false
+	}
+
+	if fileInfo, err := os.Stat(arg); err == nil && !fileInfo.IsDir() {
+		log.Printf("Warning: %q is the %s command. Use ./%s or -- %s to process the file.", arg, cmd.name, arg, arg)
+	}
+
+	return cmd, true
+}
+
+// newFlagSet returns a flag set for the command, with usage information that includes the command description.
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), cmd.description)
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", values.AppName, cmd.name, cmd.usage)
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+func printCommands() {
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %s %s %s\n", values.AppName, cmd.name, cmd.usage)
+		fmt.Printf("    \t%s\n", cmd.description)
+	}
+	fmt.Printf("Use '%s <command> -h' for options of a command.\n", values.AppName)
+}
+
+// exitWithCounts exits with the exit code that matches the number of processed and failed items.
+func exitWithCounts(countDone, countFail int) {
+	switch {
+	case countFail > 0 && countDone == 0:
+		os.Exit(exitCodeAllFailed)
+	case countFail > 0:
+		os.Exit(exitCodeSomeFailed)
+	case countDone == 0:
+		os.Exit(exitCodeNothingToDo)
+	default:
+		os.Exit(exitCodeOK)
+	}
+}
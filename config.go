@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/marko-gacesa/fenc/internal/config"
+)
+
+// configSetting maps a setting of the config file to the option of the main command.
+type configSetting struct {
+	name         string
+	flag         string
+	list         bool     // the option can be repeated, so the setting is an array
+	overriddenBy []string // the setting is ignored if any of these options is used
+}
+
+var configSettings = []configSetting{
+	{name: "hash", flag: "s"},
+	{name: "strict", flag: "strict"},
+	{name: "compress", flag: "compress"},
+	{name: "pad", flag: "pad"},
+	{name: "deterministic", flag: "deterministic"},
+	{name: "armor", flag: "armor"},
+	{name: "sign", flag: "sign"},
+	{name: "signer", flag: "signer"},
+	{name: "include", flag: "include", list: true},
+	{name: "exclude", flag: "exclude", list: true},
+	{name: "exclude-from", flag: "exclude-from", list: true},
+	{name: "out-dir", flag: "out-dir", overriddenBy: []string{"o", "O"}},
+	{name: "suffix", flag: "suffix"},
+	{name: "strip-suffix", flag: "strip-suffix"},
+	{name: "collision", flag: "collision", overriddenBy: []string{"force", "skip-existing"}},
+	{name: "keep", flag: "k"},
+	{name: "keep-going", flag: "keep-going"},
+	{name: "no-color", flag: "c"},
+	{name: "quiet", flag: "q"},
+	{name: "min-strength", flag: "min-strength"},
+	{name: "key-env", flag: "P", overriddenBy: []string{"p", "b", "key"}},
+	{name: "key", flag: "key", overriddenBy: []string{"p", "b", "P"}},
+}
+
+// unsupportedSettings are the settings that might be expected in the config file, but fenc doesn't support,
+// with the reason. They are rejected with a clear error, instead of as unknown settings.
+var unsupportedSettings = map[string]string{
+	"cipher":     "fenc always uses AES",
+	"kdf":        "fenc has no key derivation function to choose",
+	"recipients": "fenc encrypts with a key phrase or a master key, not for recipients",
+}
+
+func findConfigSetting(name string) (configSetting, bool) {
+	for _, s := range configSettings {
+		if s.name == name {
+			return s, true
+		}
+	}
+
+	return configSetting{}, false
+}
+
+// checkConfigSetting returns the setting of the config file with the name, or an error if there is none.
+func checkConfigSetting(name string, value config.Value) (configSetting, error) {
+	s, ok := findConfigSetting(name)
+	if ok {
+		return s, nil
+	}
+
+	if reason, ok := unsupportedSettings[name]; ok {
+		return configSetting{}, fmt.Errorf("unsupported setting %q in %s: %s", name, value.Source, reason)
+	}
+
+	return configSetting{}, fmt.Errorf("unknown setting %q in %s", name, value.Source)
+}
+
+// configConflicts returns, for each setting, the settings whose option overrides it or is overridden by its option.
+func configConflicts() map[string][]string {
+	conflicts := map[string][]string{}
+
+	for _, s := range configSettings {
+		for _, other := range configSettings {
+			if slices.Contains(s.overriddenBy, other.flag) || slices.Contains(other.overriddenBy, s.flag) {
+				conflicts[s.name] = append(conflicts[s.name], other.name)
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// applyConfig sets the options of the main command from the config file, for the provided profile.
+// Options set in the command line are not changed. Settings of the profile displace conflicting defaults,
+// like a key of the profile and a key environment variable of the defaults. The applied options are added to flagsSet.
+func applyConfig(fs *flag.FlagSet, profile string, flagsSet map[string]bool) error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	settings, err := c.Settings(profile, configConflicts())
+	if err != nil {
+		return err
+	}
+
+	cliSet := make(map[string]bool, len(flagsSet))
+	for name := range flagsSet {
+		cliSet[name] = true
+	}
+
+	for name, value := range settings {
+		s, err := checkConfigSetting(name, value)
+		if err != nil {
+			return err
+		}
+
+		if cliSet[s.flag] || anySet(cliSet, s.overriddenBy) {
+			continue
+		}
+
+		if len(value.Values) != 1 && !s.list {
+			return fmt.Errorf("setting %q in %s must have a single value", name, value.Source)
+		}
+
+		for _, v := range value.Values {
+			if err := fs.Set(s.flag, v); err != nil {
+				return fmt.Errorf("setting %q in %s: %w", name, value.Source, err)
+			}
+		}
+
+		flagsSet[s.flag] = true
+	}
+
+	return nil
+}
+
+func anySet(flagsSet map[string]bool, names []string) bool {
+	for _, name := range names {
+		if flagsSet[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runConfig(args []string) {
+	cmd, _ := findCommand("config")
+	fs := newFlagSet(cmd)
+	profile := fs.String("profile", "", "Show the settings of the named profile.")
+
+	if len(args) == 0 || args[0] != "show" {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	_ = fs.Parse(args[1:])
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	c, err := config.Load()
+	if err != nil {
+		fatalf("Config error: %s", err.Error())
+	}
+
+	settings, err := c.Settings(*profile, configConflicts())
+	if err != nil {
+		fatalf("Config error: %s", err.Error())
+	}
+
+	for name, value := range settings {
+		if _, err := checkConfigSetting(name, value); err != nil {
+			fatalf("Config error: %s", err.Error())
+		}
+	}
+
+	if _, err := os.Stat(c.Path); err != nil {
+		fmt.Printf("# config file: %s (not found)\n", c.Path)
+	} else {
+		fmt.Printf("# config file: %s\n", c.Path)
+	}
+
+	if names := c.ProfileNames(); len(names) > 0 {
+		fmt.Printf("# profiles: %s\n", strings.Join(names, ", "))
+	}
+
+	if *profile != "" {
+		fmt.Printf("# profile: %s\n", *profile)
+	}
+
+	for _, s := range configSettings {
+		value, ok := settings[s.name]
+		if !ok {
+			value = config.Value{Source: "default"}
+
+			if f := flag.CommandLine.Lookup(s.flag); f != nil && (f.DefValue != "" || !s.list) {
+				value.Values = []string{f.DefValue}
+			}
+		}
+
+		fmt.Printf("%s = %s # %s\n", s.name, formatSetting(s, value.Values), value.Source)
+	}
+}
+
+// formatSetting formats the value of the setting in TOML format.
+func formatSetting(s configSetting, values []string) string {
+	f := flag.CommandLine.Lookup(s.flag)
+
+	if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() && len(values) == 1 {
+		if _, err := strconv.ParseBool(values[0]); err == nil {
+			return values[0]
+		}
+	}
+
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+
+	if s.list {
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	if len(quoted) == 0 {
+		return `""`
+	}
+
+	return quoted[0]
+}
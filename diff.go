@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"fmt"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/diff"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/processor"
+)
+
+func runDiff(args []string) {
+	cmd, _ := findCommand("diff")
+	fs := newFlagSet(cmd)
+	keys := keyFlags(fs)
+	context := fs.Int("U", 3, "Number of context lines.")
+	textconv := fs.Bool("textconv", false, "Print the content of a single file, decrypted if it's encrypted. For use as git textconv driver.")
+	_ = fs.Parse(args)
+
+	if (*textconv && fs.NArg() != 1) || (!*textconv && fs.NArg() != 2) || *context < 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	var block cipher.Block
+	getBlock := func() (cipher.Block, error) {
+		if block != nil {
+			return block, nil
+		}
+
+		var err error
+		block, err = readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+		return block, err
+	}
+
+	if *textconv {
+		data, err := readContent(fs.Arg(0), getBlock)
+		if err != nil {
+			fatalf("Failed to read %q: %s", fs.Arg(0), err.Error())
+		}
+
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+
+	var contents [2][]byte
+
+	for i, fileName := range fs.Args() {
+		data, err := readContent(fileName, getBlock)
+		if err != nil {
+			fatalf("Failed to read %q: %s", fileName, err.Error())
+		}
+
+		contents[i] = data
+	}
+
+	out := diff.Unified(fs.Arg(0), fs.Arg(1), contents[0], contents[1], *context)
+	if out == "" {
+		os.Exit(exitCodeOK)
+	}
+
+	fmt.Print(out)
+
+	os.Exit(exitCodeDiffer)
+}
+
+// readContent returns the content of the file, decrypted in memory if the file is encrypted.
+// The cipher block is requested only for encrypted files.
+func readContent(fileName string, getBlock func() (cipher.Block, error)) ([]byte, error) {
+	_, encrypted, err := processor.ProbeFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted {
+		return os.ReadFile(fileName)
+	}
+
+	block, err := getBlock()
+	if err != nil {
+		return nil, fmt.Errorf("key phrase error: %w", err)
+	}
+
+	var content bytes.Buffer
+	if err = processor.DecryptToWriter(processor.DecryptOptions{}, block, fileName, &content); err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
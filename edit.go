@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/signing"
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+func runEdit(args []string) {
+	cmd, _ := findCommand("edit")
+	fs := newFlagSet(cmd)
+	keys := keyFlags(fs)
+	signKey := fs.String("sign", "", "Embed a new signature made with the provided private key. Without it, signatures are removed.")
+	padName := fs.String("pad", "none", "Length-hiding padding. See the main usage.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	fileName := fs.Arg(0)
+
+	pad, err := padding.FromName(*padName)
+	if err != nil {
+		fatalf("Padding error: %s", err.Error())
+	}
+
+	var signer ed25519.PrivateKey
+	if *signKey != "" {
+		signer, err = signing.ReadPrivateKey(*signKey)
+		if err != nil {
+			fatalf("Signing key error: %s", err.Error())
+		}
+	}
+
+	h, err := processor.ReadHeaderFile(fileName)
+	if err != nil {
+		fatalf("Input file error: %s", err.Error())
+	}
+
+	armored, err := processor.IsArmoredFile(fileName)
+	if err != nil {
+		fatalf("Input file error: %s", err.Error())
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	dir, err := file.PrivateDir("fenc-edit-")
+	if err != nil {
+		fatalf("Edit error: %s", err.Error())
+	}
+
+	var wipeOnce sync.Once
+	wipe := func() {
+		wipeOnce.Do(func() {
+			if err := file.WipeDir(dir); err != nil {
+				log.Printf("Failed to wipe decrypted data: %s", err.Error())
+			}
+		})
+	}
+
+	// Interrupts are left to the editor. Anything that terminates fenc wipes the decrypted data first.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt}, terminationSignals...)...)
+	go func() {
+		for sig := range signals {
+			if sig == os.Interrupt {
+				continue
+			}
+
+			wipe()
+			os.Exit(exitCodeAllFailed)
+		}
+	}()
+
+	opts := processor.EncryptOptions{
+		HashID:        h.GetHashID(),
+		Compression:   h.GetCompression(),
+		Padding:       pad,
+		Signer:        signer,
+		WrapKey:       true,
+		Deterministic: h.IsDeterministic(),
+		Armor:         armored,
+	}
+
+	changed, err := editFile(opts, block, fileName, dir)
+
+	signal.Stop(signals)
+	wipe()
+
+	if err != nil {
+		fatalf("Edit error: %s", err.Error())
+	}
+
+	if !changed {
+		fmt.Printf("%s: unchanged\n", fileName)
+		os.Exit(exitCodeNothingToDo)
+	}
+
+	if h.IsSigned() && signer == nil {
+		fmt.Printf("%s: DONE (signature removed)\n", fileName)
+	} else {
+		fmt.Printf("%s: DONE\n", fileName)
+	}
+}
+
+// editFile decrypts the file into the private directory, opens it in the editor and,
+// if the content changed, encrypts it again and replaces the original. It reports whether the content changed.
+func editFile(opts processor.EncryptOptions, block cipher.Block, fileName, dir string) (bool, error) {
+	plainFile := filepath.Join(dir, strings.TrimSuffix(filepath.Base(fileName), values.Extension))
+
+	f, err := os.OpenFile(plainFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %q: %w", plainFile, err)
+	}
+	_ = f.Close()
+
+	if err = processor.DecryptToFile(processor.DecryptOptions{}, block, fileName, plainFile); err != nil {
+		return false, err
+	}
+
+	sumBefore, err := fileSum(plainFile)
+	if err != nil {
+		return false, err
+	}
+
+	if err = runEditor(plainFile); err != nil {
+		return false, err
+	}
+
+	sumAfter, err := fileSum(plainFile)
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(sumBefore, sumAfter) {
+		return false, nil
+	}
+
+	var verifyOpts processor.DecryptOptions
+	if opts.Signer != nil {
+		verifyOpts.Signer = opts.Signer.Public().(ed25519.PublicKey)
+	}
+
+	err = file.Replace(fileName, func(tempName string) error {
+		if err := processor.EncryptFile(opts, block, plainFile, tempName); err != nil {
+			return err
+		}
+
+		if err := processor.VerifyFile(verifyOpts, block, tempName); err != nil {
+			return fmt.Errorf("verification failed: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// runEditor opens the file in the editor from $VISUAL or $EDITOR, or in vi if neither is set.
+// The editor command may contain arguments, so it's run by the shell.
+func runEditor(fileName string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	c := exec.Command("/bin/sh", "-c", editor+` "$1"`, "sh", fileName)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	return nil
+}
+
+func fileSum(fileName string) (sum []byte, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", fileName, err)
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("failed to close %q: %w", fileName, errClose)
+		}
+	}()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", fileName, err)
+	}
+
+	return hasher.Sum(nil), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/marko-gacesa/fenc/internal/dotenv"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/processor"
+)
+
+func runExec(args []string) {
+	cmd, _ := findCommand("exec")
+	fs := newFlagSet(cmd)
+	var envFiles stringList
+	fs.Var(&envFiles, "f", "Encrypted file in dotenv format. Can be repeated, later files override earlier ones.")
+	keys := keyFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || len(envFiles) == 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	env := os.Environ()
+
+	for _, fileName := range envFiles {
+		vars, err := func() ([]dotenv.Var, error) {
+			var content bytes.Buffer
+			if err := processor.DecryptToWriter(processor.DecryptOptions{}, block, fileName, &content); err != nil {
+				return nil, err
+			}
+
+			data := content.Bytes()
+			defer clear(data)
+
+			return dotenv.Parse(bytes.NewReader(data))
+		}()
+		if err != nil {
+			fatalf("Failed to read %q: %s", fileName, err.Error())
+		}
+
+		for _, v := range vars {
+			env = setEnv(env, v.Name, v.Value)
+		}
+	}
+
+	path, err := exec.LookPath(fs.Arg(0))
+	if err != nil {
+		fatalf("Command error: %s", err.Error())
+	}
+
+	err = execCommand(path, fs.Args(), env)
+	fatalf("Command error: failed to execute %q: %s", path, err.Error())
+}
+
+// setEnv sets the variable in the environment list in "NAME=value" format, replacing the existing definition.
+func setEnv(env []string, name, value string) []string {
+	prefix := name + "="
+	for i, def := range env {
+		if strings.HasPrefix(def, prefix) {
+			env[i] = prefix + value
+			return env
+		}
+	}
+
+	return append(env, prefix+value)
+}
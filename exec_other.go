@@ -0,0 +1,40 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execCommand runs the command and exits with its exit code, because fenc can't be replaced with the command
+// on this platform. Interrupts are left to the command. It returns only if the command can't be started.
+func execCommand(path string, args, env []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signal.Ignore(os.Interrupt)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	err := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		os.Exit(exitErr.ExitCode())
+	case err != nil:
+		os.Exit(exitCodeAllFailed)
+	}
+
+	os.Exit(exitCodeOK)
+
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// execCommand replaces fenc with the command, so it receives all signals directly
+// and its exit code is the exit code of fenc. It returns only if the command can't be executed.
+func execCommand(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
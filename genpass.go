@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/password"
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+func runGenpass(args []string) {
+	cmd, _ := findCommand("genpass")
+	fs := newFlagSet(cmd)
+	wordCount := fs.Int("words", 6, "Generate a passphrase of the provided number of random words, which fit in the 32 bytes that make the key.")
+	charCount := fs.Int("chars", 0, "Generate a password of the provided number of random characters, at most 32, instead of words.")
+	sep := fs.String("sep", " ", "Separator of words.")
+	check := fs.Bool("check", false, "Don't generate anything. Ask for a key phrase and show its estimated strength.")
+	_ = fs.Parse(args)
+
+	flagsSet := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		flagsSet[f.Name] = true
+	})
+
+	if fs.NArg() != 0 || (flagsSet["words"] && flagsSet["chars"]) {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	if *check {
+		key, err := password.InputWithPrompt("Enter key phrase", password.NoStrengthCheck, false)
+		if err != nil {
+			fatalf("Key phrase error: %s", err.Error())
+		}
+
+		s := password.Estimate(key)
+		secure.Wipe(key)
+
+		fmt.Printf("Strength: %s\n", s)
+		for _, line := range s.Feedback {
+			fmt.Printf("  %s\n", line)
+		}
+
+		if s.Score < password.DefaultMinScore {
+			os.Exit(exitCodeSomeFailed)
+		}
+
+		return
+	}
+
+	var (
+		phrase  []byte
+		entropy float64
+		err     error
+	)
+
+	if flagsSet["chars"] {
+		phrase, entropy, err = password.GenerateChars(*charCount)
+	} else {
+		phrase, entropy, err = password.GenerateWords(*wordCount, *sep)
+	}
+	if err != nil {
+		fatalf("Generate error: %s", err.Error())
+	}
+
+	defer secure.Wipe(phrase)
+
+	_, _ = os.Stdout.Write(phrase)
+	fmt.Println()
+
+	// the entropy goes to stderr, so the key phrase alone can be piped or captured
+	log.Printf("Entropy: %.0f bits, strength score: %d of %d", entropy, password.Estimate(phrase).Score, password.MaxScore)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/marko-gacesa/fenc/internal/compress"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+// gitFilterName is the name of the filter and the diff driver in git configuration and attributes.
+const gitFilterName = "fenc"
+
+func runGitFilter(args []string) {
+	cmd, _ := findCommand("git-filter")
+	fs := newFlagSet(cmd)
+	keys := keyFlags(fs)
+	_ = fs.Parse(args)
+
+	// git runs the filter without a terminal, with stdout as the content, so the key phrase can't be asked for
+	keys.noPrompt = true
+
+	if fs.NArg() != 1 || (fs.Arg(0) != "clean" && fs.Arg(0) != "smudge") {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fatalf("Input error: %s", err.Error())
+	}
+
+	_, encrypted, err := processor.Probe(bytes.NewReader(data))
+	if err != nil {
+		fatalf("Input error: %s", err.Error())
+	}
+
+	// Already encrypted content is not encrypted again and content that isn't encrypted,
+	// like files committed before the filter was set up, is checked out as it is.
+	if (fs.Arg(0) == "clean") == encrypted {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if errors.Is(err, keyprovider.ErrorUnavailable) {
+		fatalf("Key phrase error: the git filter can't ask for the key phrase, set %s or add the key to the agent", defaultKeyPhraseEnv)
+	} else if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	if fs.Arg(0) == "smudge" {
+		err = processor.Decrypt(processor.DecryptOptions{}, block, bytes.NewReader(data), os.Stdout)
+		if err != nil {
+			fatalf("Decrypt error: %s", err.Error())
+		}
+
+		return
+	}
+
+	comp, _ := compress.FromName("auto")
+
+	opts := processor.EncryptOptions{
+		HashID:        uint(crypto.SHA256),
+		Compression:   comp,
+		Deterministic: true,
+	}
+
+	if err = processor.EncryptBytes(opts, block, data, os.Stdout); err != nil {
+		fatalf("Encrypt error: %s", err.Error())
+	}
+}
+
+func runGitInit(args []string) {
+	cmd, _ := findCommand("git-init")
+	fs := newFlagSet(cmd)
+	keyEnv := fs.String("P", "", "Environment variable with the key phrase, used by the filter.\n"+
+		"By default, the filter uses "+defaultKeyPhraseEnv+", the key agent or asks for the key phrase, like other commands.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	topLevel, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		fatalf("Git error: %s", err.Error())
+	}
+
+	keyOption := ""
+	if *keyEnv != "" {
+		keyOption = " -P " + *keyEnv
+	}
+
+	config := [][2]string{
+		{"filter." + gitFilterName + ".clean", values.AppName + " git-filter" + keyOption + " clean"},
+		{"filter." + gitFilterName + ".smudge", values.AppName + " git-filter" + keyOption + " smudge"},
+		{"filter." + gitFilterName + ".required", "true"},
+		{"diff." + gitFilterName + ".textconv", values.AppName + " diff -textconv" + keyOption},
+	}
+
+	for _, kv := range config {
+		if _, err = gitOutput("config", "--local", kv[0], kv[1]); err != nil {
+			fatalf("Git error: %s", err.Error())
+		}
+	}
+
+	attributesFile := filepath.Join(topLevel, ".gitattributes")
+
+	added, err := addGitAttributes(attributesFile, fs.Args())
+	if err != nil {
+		fatalf("Git attributes error: %s", err.Error())
+	}
+
+	fmt.Printf("Filter %q registered in git configuration.\n", gitFilterName)
+	for _, line := range added {
+		fmt.Printf("Added to %s: %s\n", attributesFile, line)
+	}
+
+	if *keyEnv != "" {
+		fmt.Printf("The filter reads the key phrase from the environment variable %s.\n", *keyEnv)
+	} else {
+		fmt.Printf("The filter reads the key phrase from %s, the key agent or asks for it.\n", defaultKeyPhraseEnv)
+	}
+}
+
+// addGitAttributes adds the attributes that assign the filter and the diff driver to the patterns.
+// It returns the added lines. Patterns already assigned are skipped.
+func addGitAttributes(fileName string, patterns []string) (added []string, err error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	existing := strings.Split(string(content), "\n")
+	for i := range existing {
+		existing[i] = strings.Join(strings.Fields(existing[i]), " ")
+	}
+
+	for _, pattern := range patterns {
+		line := fmt.Sprintf("%s filter=%s diff=%s", pattern, gitFilterName, gitFilterName)
+		if !slices.Contains(existing, line) && !slices.Contains(added, line) {
+			added = append(added, line)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	var sb strings.Builder
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		sb.WriteByte('\n')
+	}
+	for _, line := range added {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+
+	_, err = f.WriteString(sb.String())
+
+	return added, err
+}
+
+// gitOutput runs the git command and returns its trimmed output.
+func gitOutput(args ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	c := exec.Command("git", args...)
+	c.Stderr = &stderr
+
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -3,13 +3,16 @@ module github.com/marko-gacesa/fenc
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/fatih/color v1.17.0
+	github.com/klauspost/compress v1.17.9
 	github.com/marko-gacesa/cipherio v0.0.0-20220715134703-f7e5b9b50d2b
+	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
+	golang.org/x/text v0.15.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.20.0 // indirect
 )
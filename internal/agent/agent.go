@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+// The agent keeps keys in memory for a limited time and hands them out over a unix socket.
+// The protocol is line based: a request is a command followed by space separated arguments,
+// the response is "OK", optionally followed by a value, or "ERR" followed by the error message.
+// Binary values are hex encoded.
+//
+//	ADD <name> <ttl_seconds> <key>   stores the key, the ttl of 0 means the default ttl
+//	GET <name>                       returns the key
+//	CLEAR                            removes all keys
+//	LOCK <passphrase>                refuses GET and ADD until unlocked with the same passphrase
+//	UNLOCK <passphrase>
+//	STOP                             removes all keys and stops the agent
+
+// EnvSocket is the environment variable with the path of the agent socket.
+const EnvSocket = "FENC_AGENT_SOCK"
+
+// DefaultKeyName is the name of the key used when no name is provided.
+const DefaultKeyName = "default"
+
+const (
+	msgNotFound = "not found"
+	msgLocked   = "locked"
+)
+
+// maxLineSize limits the size of a request.
+const maxLineSize = 4096
+
+type entry struct {
+	key   []byte
+	timer *time.Timer
+}
+
+// Agent holds the keys. Create it with New and start it with Serve.
+type Agent struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]*entry
+	lockHash []byte // the hash of the lock passphrase, if the agent is locked
+	listener net.Listener
+	stopped  bool
+}
+
+// New returns an agent that keeps keys for the provided time, unless a key is added with its own time.
+func New(ttl time.Duration) *Agent {
+	return &Agent{
+		ttl:  ttl,
+		keys: map[string]*entry{},
+	}
+}
+
+// Serve accepts connections until Stop is called or the STOP command is received.
+func (a *Agent) Serve(listener net.Listener) error {
+	a.mu.Lock()
+	a.listener = listener
+	stopped := a.stopped
+	a.mu.Unlock()
+
+	if stopped {
+		return listener.Close()
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			a.mu.Lock()
+			stopped := a.stopped
+			a.mu.Unlock()
+
+			if stopped {
+				return nil
+			}
+
+			return err
+		}
+
+		go a.handle(conn)
+	}
+}
+
+// Stop wipes all keys and stops accepting connections.
+func (a *Agent) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clear()
+	a.stopped = true
+
+	if a.listener != nil {
+		_ = a.listener.Close()
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReaderSize(conn, maxLineSize)
+
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil {
+			clear(line)
+			return
+		}
+
+		response := a.execute(bytes.Fields(line))
+		clear(line)
+
+		_, err = conn.Write(response)
+		clear(response)
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) execute(fields [][]byte) []byte {
+	if len(fields) == 0 {
+		return errorResponse("empty request")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cmd, args := string(fields[0]), fields[1:]
+
+	switch {
+	case cmd == "ADD" && len(args) == 3:
+		if a.lockHash != nil {
+			return errorResponse(msgLocked)
+		}
+
+		seconds, err := strconv.ParseUint(string(args[1]), 10, 32)
+		if err != nil {
+			return errorResponse("invalid ttl")
+		}
+
+		key, err := decodeHex(args[2])
+		if err != nil {
+			return errorResponse("invalid key")
+		}
+
+		ttl := a.ttl
+		if seconds > 0 {
+			ttl = time.Duration(seconds) * time.Second
+		}
+
+		a.add(string(args[0]), key, ttl)
+
+		return []byte("OK\n")
+
+	case cmd == "GET" && len(args) == 1:
+		if a.lockHash != nil {
+			return errorResponse(msgLocked)
+		}
+
+		e, ok := a.keys[string(args[0])]
+		if !ok {
+			return errorResponse(msgNotFound)
+		}
+
+		response := make([]byte, 3+hex.EncodedLen(len(e.key))+1)
+		copy(response, "OK ")
+		hex.Encode(response[3:], e.key)
+		response[len(response)-1] = '\n'
+
+		return response
+
+	case cmd == "CLEAR" && len(args) == 0:
+		a.clear()
+		return []byte("OK\n")
+
+	case cmd == "LOCK" && len(args) == 1:
+		if a.lockHash != nil {
+			return errorResponse("already locked")
+		}
+
+		sum := sha256.Sum256(args[0])
+		a.lockHash = sum[:]
+
+		return []byte("OK\n")
+
+	case cmd == "UNLOCK" && len(args) == 1:
+		if a.lockHash == nil {
+			return errorResponse("not locked")
+		}
+
+		sum := sha256.Sum256(args[0])
+		if subtle.ConstantTimeCompare(sum[:], a.lockHash) != 1 {
+			return errorResponse("wrong passphrase")
+		}
+
+		a.lockHash = nil
+
+		return []byte("OK\n")
+
+	case cmd == "STOP" && len(args) == 0:
+		a.clear()
+		a.stopped = true
+
+		if a.listener != nil {
+			_ = a.listener.Close()
+		}
+
+		return []byte("OK\n")
+
+	default:
+		return errorResponse("invalid request")
+	}
+}
+
+// add stores the key, which is copied to locked memory. The caller must hold the lock.
+func (a *Agent) add(name string, key []byte, ttl time.Duration) {
+	a.remove(name)
+
+	e := &entry{key: secure.LockedCopy(key)}
+	clear(key)
+
+	e.timer = time.AfterFunc(ttl, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.keys[name] == e {
+			a.remove(name)
+		}
+	})
+
+	a.keys[name] = e
+}
+
+// remove wipes the key. The caller must hold the lock.
+func (a *Agent) remove(name string) {
+	e, ok := a.keys[name]
+	if !ok {
+		return
+	}
+
+	e.timer.Stop()
+	secure.Wipe(e.key)
+	delete(a.keys, name)
+}
+
+// clear wipes all keys. The caller must hold the lock.
+func (a *Agent) clear() {
+	for name := range a.keys {
+		a.remove(name)
+	}
+}
+
+func decodeHex(data []byte) ([]byte, error) {
+	decoded := make([]byte, hex.DecodedLen(len(data)))
+
+	if _, err := hex.Decode(decoded, data); err != nil {
+		return nil, errors.New("invalid hex value")
+	}
+
+	return decoded, nil
+}
+
+func errorResponse(msg string) []byte {
+	return []byte("ERR " + msg + "\n")
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startAgent(t *testing.T, ttl time.Duration) (string, *Agent) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	a := New(ttl)
+	go func() { _ = a.Serve(listener) }()
+
+	t.Cleanup(a.Stop)
+
+	return socket, a
+}
+
+func TestAgent(t *testing.T) {
+	socket, _ := startAgent(t, time.Hour)
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	if _, err := Get(socket, DefaultKeyName); err != ErrorNotFound {
+		t.Errorf("error mismatch for missing key: got=%v want=%v", err, ErrorNotFound)
+	}
+
+	if err := Add(socket, DefaultKeyName, key, 0); err != nil {
+		t.Errorf("failed to add key: %v", err)
+		return
+	}
+
+	got, err := Get(socket, DefaultKeyName)
+	if err != nil {
+		t.Errorf("failed to get key: %v", err)
+		return
+	}
+
+	if !bytes.Equal(got, key) {
+		t.Errorf("key mismatch: got=%x want=%x", got, key)
+	}
+
+	if err = Lock(socket, []byte("lock pass")); err != nil {
+		t.Errorf("failed to lock: %v", err)
+	}
+
+	if _, err = Get(socket, DefaultKeyName); err != ErrorLocked {
+		t.Errorf("error mismatch for locked agent: got=%v want=%v", err, ErrorLocked)
+	}
+
+	if err = Unlock(socket, []byte("wrong")); err == nil {
+		t.Error("unlocked with wrong passphrase")
+	}
+
+	if err = Unlock(socket, []byte("lock pass")); err != nil {
+		t.Errorf("failed to unlock: %v", err)
+	}
+
+	if _, err = Get(socket, DefaultKeyName); err != nil {
+		t.Errorf("failed to get key after unlock: %v", err)
+	}
+
+	if err = Clear(socket); err != nil {
+		t.Errorf("failed to clear: %v", err)
+	}
+
+	if _, err = Get(socket, DefaultKeyName); err != ErrorNotFound {
+		t.Errorf("error mismatch for cleared key: got=%v want=%v", err, ErrorNotFound)
+	}
+
+	if err = Add(socket, "bad name", key, 0); err != ErrorInvalidName {
+		t.Errorf("error mismatch for invalid name: got=%v want=%v", err, ErrorInvalidName)
+	}
+}
+
+func TestAgentExpiry(t *testing.T) {
+	socket, a := startAgent(t, time.Hour)
+
+	a.mu.Lock()
+	a.add(DefaultKeyName, []byte("0123456789abcdef"), 10*time.Millisecond)
+	a.mu.Unlock()
+
+	if _, err := Get(socket, DefaultKeyName); err != nil {
+		t.Errorf("failed to get key: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := Get(socket, DefaultKeyName); err != ErrorNotFound {
+		t.Errorf("error mismatch for expired key: got=%v want=%v", err, ErrorNotFound)
+	}
+}
+
+func TestAgentStop(t *testing.T) {
+	socket, _ := startAgent(t, time.Hour)
+
+	if err := Stop(socket); err != nil {
+		t.Errorf("failed to stop: %v", err)
+	}
+
+	if _, err := Get(socket, DefaultKeyName); err == nil {
+		t.Error("agent still running")
+	}
+}
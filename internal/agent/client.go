@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+var (
+	ErrorNotFound    = errors.New("agent: key not found")
+	ErrorLocked      = errors.New("agent: locked")
+	ErrorInvalidName = errors.New("agent: invalid key name")
+)
+
+// clientTimeout limits the time of a single request, so that a stuck agent doesn't block the caller.
+const clientTimeout = 5 * time.Second
+
+// Get returns the key with the provided name from the agent listening on the socket.
+func Get(socket, name string) ([]byte, error) {
+	if !validName(name) {
+		return nil, ErrorInvalidName
+	}
+
+	value, err := request(socket, []byte("GET "+name))
+	if err != nil {
+		return nil, err
+	}
+
+	defer clear(value)
+
+	key := secure.Alloc(hex.DecodedLen(len(value)))
+	if _, err = hex.Decode(key, value); err != nil {
+		secure.Wipe(key)
+		return nil, errors.New("agent: invalid key")
+	}
+
+	return key, nil
+}
+
+// Add stores the key in the agent. A zero ttl means the default time of the agent.
+func Add(socket, name string, key []byte, ttl time.Duration) error {
+	if !validName(name) {
+		return ErrorInvalidName
+	}
+
+	req := []byte("ADD " + name + " " + strconv.FormatInt(int64(ttl/time.Second), 10) + " ")
+	req = append(req, make([]byte, hex.EncodedLen(len(key)))...)
+	hex.Encode(req[len(req)-hex.EncodedLen(len(key)):], key)
+
+	defer clear(req)
+
+	_, err := request(socket, req)
+
+	return err
+}
+
+// Clear removes all keys from the agent.
+func Clear(socket string) error {
+	_, err := request(socket, []byte("CLEAR"))
+	return err
+}
+
+// Lock makes the agent refuse requests for keys until it's unlocked with the same passphrase.
+func Lock(socket string, passphrase []byte) error {
+	return lockCommand(socket, "LOCK ", passphrase)
+}
+
+// Unlock unlocks the agent locked with the passphrase.
+func Unlock(socket string, passphrase []byte) error {
+	return lockCommand(socket, "UNLOCK ", passphrase)
+}
+
+// Stop removes all keys and stops the agent.
+func Stop(socket string) error {
+	_, err := request(socket, []byte("STOP"))
+	return err
+}
+
+// validName reports whether the key name is not empty and has only printable characters other than space.
+func validName(name string) bool {
+	for _, c := range []byte(name) {
+		if c <= ' ' || c >= 0x7f {
+			return false
+		}
+	}
+
+	return name != ""
+}
+
+func lockCommand(socket, cmd string, passphrase []byte) error {
+	req := []byte(cmd)
+	req = append(req, make([]byte, hex.EncodedLen(len(passphrase)))...)
+	hex.Encode(req[len(cmd):], passphrase)
+
+	defer clear(req)
+
+	_, err := request(socket, req)
+
+	return err
+}
+
+// request sends the request and returns the value of the response.
+func request(socket string, req []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socket, clientTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("agent: failed to connect: %w", err)
+	}
+
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	// the request can contain a key, so the copy with the line end is wiped too
+	req = append(req, '\n')
+	defer clear(req)
+
+	if _, err = conn.Write(req); err != nil {
+		return nil, fmt.Errorf("agent: failed to send request: %w", err)
+	}
+
+	response, err := bufio.NewReaderSize(conn, maxLineSize).ReadSlice('\n')
+	if err != nil {
+		return nil, fmt.Errorf("agent: failed to read response: %w", err)
+	}
+
+	response = bytes.TrimSuffix(response, []byte("\n"))
+
+	switch {
+	case bytes.Equal(response, []byte("OK")):
+		return nil, nil
+	case bytes.HasPrefix(response, []byte("OK ")):
+		return response[3:], nil
+	case bytes.Equal(response, []byte("ERR "+msgNotFound)):
+		return nil, ErrorNotFound
+	case bytes.Equal(response, []byte("ERR "+msgLocked)):
+		return nil, ErrorLocked
+	case bytes.HasPrefix(response, []byte("ERR ")):
+		return nil, errors.New("agent: " + string(response[4:]))
+	default:
+		return nil, errors.New("agent: invalid response")
+	}
+}
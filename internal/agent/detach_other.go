@@ -0,0 +1,8 @@
+//go:build !unix
+
+package agent
+
+import "os/exec"
+
+// Detach does nothing on this platform.
+func Detach(cmd *exec.Cmd) {}
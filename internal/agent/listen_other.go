@@ -0,0 +1,10 @@
+//go:build !unix
+
+package agent
+
+import "net"
+
+// Listen creates the socket. Access to it depends on the permissions of the directory on this platform.
+func Listen(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package agent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Listen creates the socket, accessible only by the user. The umask is set while the socket is created,
+// so other users can't connect to it before its mode is changed.
+func Listen(socket string) (net.Listener, error) {
+	oldMask := unix.Umask(0o177)
+	defer unix.Umask(oldMask)
+
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestListenMode(t *testing.T) {
+	oldMask := unix.Umask(0)
+	defer unix.Umask(oldMask)
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	defer listener.Close()
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("failed to stat: %v", err)
+	}
+
+	if got, want := info.Mode().Perm(), os.FileMode(0o600); got != want {
+		t.Errorf("mode mismatch: got=%v want=%v", got, want)
+	}
+
+	if got, want := unix.Umask(oldMask), 0; got != want {
+		t.Errorf("umask not restored: got=%o want=%o", got, want)
+	}
+}
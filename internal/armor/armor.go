@@ -0,0 +1,308 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Armored data is the encrypted data encoded as text, so it can be pasted into tickets, chat or config files:
+//
+//	-----BEGIN FENC MESSAGE-----
+//	<base64, in lines of 64 characters>
+//	=<base64 of the CRC-24 of the data>
+//	-----END FENC MESSAGE-----
+//
+// The checksum is the CRC-24 of OpenPGP (RFC 4880). It only detects damage in transport,
+// the encrypted data is authenticated anyway. When reading, whitespace and quoting around lines,
+// like indentation, quotes, email quote marks and escaped line breaks of JSON strings, are ignored.
+
+const (
+	BeginLine  = "-----BEGIN FENC MESSAGE-----"
+	EndLine    = "-----END FENC MESSAGE-----"
+	LineLength = 64
+)
+
+var (
+	ErrorNoBegin  = errors.New("armor: no " + BeginLine + " line")
+	ErrorNoEnd    = errors.New("armor: no " + EndLine + " line (truncated?)")
+	ErrorChecksum = errors.New("armor: checksum mismatch (damaged in transport?)")
+	ErrorInvalid  = errors.New("armor: invalid data")
+)
+
+// quoting are the characters that can surround armored lines: whitespace, quotes, backticks of code blocks,
+// email quote marks and YAML block indicators.
+const quoting = " \t\r\n\"'`>|"
+
+// detectSize is the size of the beginning of the data that is searched for the begin line.
+const detectSize = 512
+
+// Detect reports whether the data read by the reader is armored: whether it starts with the begin line,
+// possibly after whitespace and quoting. The data is not consumed.
+func Detect(r *bufio.Reader) (bool, error) {
+	prefix, err := r.Peek(detectSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return false, fmt.Errorf("armor: failed to read: %w", err)
+	}
+
+	return IsArmored(prefix), nil
+}
+
+// IsArmored reports whether the data starts with the begin line, possibly after whitespace and quoting.
+func IsArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, quoting), []byte(BeginLine))
+}
+
+// NewWriter returns the writer that writes the data armored to w. It must be closed to write the end.
+func NewWriter(w io.Writer) io.WriteCloser {
+	lw := &lineWriter{w: w}
+	return &writer{w: w, lw: lw, enc: base64.NewEncoder(base64.StdEncoding, lw), crc: crcInit}
+}
+
+type writer struct {
+	w       io.Writer
+	lw      *lineWriter
+	enc     io.WriteCloser
+	crc     uint32
+	started bool
+}
+
+func (w *writer) start() error {
+	if w.started {
+		return nil
+	}
+
+	w.started = true
+
+	_, err := io.WriteString(w.w, BeginLine+"\n")
+
+	return err
+}
+
+func (w *writer) Write(data []byte) (int, error) {
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+
+	w.crc = updateCRC(w.crc, data)
+
+	return w.enc.Write(data)
+}
+
+func (w *writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	if err := w.enc.Close(); err != nil {
+		return err
+	}
+
+	if w.lw.column > 0 {
+		if _, err := io.WriteString(w.w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	sum := []byte{byte(w.crc >> 16), byte(w.crc >> 8), byte(w.crc)}
+
+	_, err := io.WriteString(w.w, "="+base64.StdEncoding.EncodeToString(sum)+"\n"+EndLine+"\n")
+
+	return err
+}
+
+// lineWriter breaks the base64 text into lines of LineLength characters.
+type lineWriter struct {
+	w      io.Writer
+	column int
+}
+
+func (lw *lineWriter) Write(data []byte) (int, error) {
+	written := 0
+
+	for len(data) > 0 {
+		n := min(len(data), LineLength-lw.column)
+
+		if _, err := lw.w.Write(data[:n]); err != nil {
+			return written, err
+		}
+
+		written += n
+		lw.column += n
+		data = data[n:]
+
+		if lw.column == LineLength {
+			if _, err := io.WriteString(lw.w, "\n"); err != nil {
+				return written, err
+			}
+
+			lw.column = 0
+		}
+	}
+
+	return written, nil
+}
+
+// NewReader returns the reader of the data armored in r. The first line with content must be the begin line.
+// Whatever follows the end line is not read. The reader fails if the checksum doesn't match.
+func NewReader(r io.Reader) io.Reader {
+	return &reader{r: bufio.NewReader(r), crc: crcInit}
+}
+
+type reader struct {
+	r       *bufio.Reader
+	lines   []string // lines from the current line of the input, split at escaped line breaks
+	text    []byte   // base64 text not decoded yet, less than 4 characters
+	decoded []byte   // decoded data not read yet
+	crc     uint32
+	begun   bool
+	sum     string
+	err     error
+}
+
+func (r *reader) Read(data []byte) (int, error) {
+	for len(r.decoded) == 0 && r.err == nil {
+		r.err = r.next()
+	}
+
+	if len(r.decoded) > 0 {
+		n := copy(data, r.decoded)
+		r.decoded = r.decoded[n:]
+
+		return n, nil
+	}
+
+	return 0, r.err
+}
+
+// next processes the next line with content. It returns io.EOF after the end line.
+func (r *reader) next() error {
+	line, err := r.nextLine()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !r.begun:
+		if line != BeginLine {
+			return ErrorNoBegin
+		}
+
+		r.begun = true
+
+	case line == EndLine:
+		return r.finish()
+
+	case r.sum != "":
+		return fmt.Errorf("%w: data after the checksum", ErrorInvalid)
+
+	case strings.HasPrefix(line, "="):
+		r.sum = line[1:]
+
+	default:
+		r.text = append(r.text, line...)
+
+		n := len(r.text) / 4 * 4
+		if err = r.decode(r.text[:n]); err != nil {
+			return err
+		}
+
+		r.text = r.text[:copy(r.text, r.text[n:])]
+	}
+
+	return nil
+}
+
+func (r *reader) decode(text []byte) error {
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+
+	n, err := base64.StdEncoding.Decode(decoded, text)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalid, err)
+	}
+
+	r.decoded = decoded[:n]
+	r.crc = updateCRC(r.crc, r.decoded)
+
+	return nil
+}
+
+func (r *reader) finish() error {
+	if len(r.text) > 0 {
+		return fmt.Errorf("%w: incomplete base64 text", ErrorInvalid)
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(r.sum)
+	if err != nil || len(sum) != 3 {
+		return fmt.Errorf("%w: missing or invalid checksum", ErrorInvalid)
+	}
+
+	if uint32(sum[0])<<16|uint32(sum[1])<<8|uint32(sum[2]) != r.crc {
+		return ErrorChecksum
+	}
+
+	return io.EOF
+}
+
+// nextLine returns the next line with content, without whitespace and quoting.
+func (r *reader) nextLine() (string, error) {
+	for {
+		for len(r.lines) > 0 {
+			line := cleanLine(r.lines[0])
+			r.lines = r.lines[1:]
+
+			if line != "" {
+				return line, nil
+			}
+		}
+
+		line, err := r.r.ReadString('\n')
+		if line == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				if !r.begun {
+					return "", ErrorNoBegin
+				}
+
+				return "", ErrorNoEnd
+			}
+
+			return "", fmt.Errorf("armor: failed to read: %w", err)
+		}
+
+		// JSON strings and shell strings can hold the armored text with escaped line breaks
+		r.lines = strings.Split(line, `\n`)
+	}
+}
+
+func cleanLine(line string) string {
+	line = strings.Trim(line, quoting)
+	line = strings.TrimSuffix(line, `\r`)
+	line = strings.TrimSuffix(line, `\`) // line continuation
+	line = strings.Trim(line, quoting+",")
+
+	return line
+}
+
+// CRC-24 of OpenPGP, RFC 4880 section 6.1.
+const (
+	crcInit = 0xB704CE
+	crcPoly = 0x1864CFB
+)
+
+func updateCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for range 8 {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crcPoly
+			}
+		}
+	}
+
+	return crc & 0xFFFFFF
+}
@@ -0,0 +1,140 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func armored(t *testing.T, data []byte) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	text := armored(t, []byte("hello"))
+	want := BeginLine + "\naGVsbG8=\n=R/WK\n" + EndLine + "\n"
+
+	if text != want {
+		t.Errorf("armored mismatch: got=%q want=%q", text, want)
+	}
+
+	lines := strings.Split(armored(t, make([]byte, 100)), "\n")
+	for _, line := range lines {
+		if len(line) > LineLength {
+			t.Errorf("line too long: %d", len(line))
+		}
+	}
+}
+
+func TestCRC(t *testing.T) {
+	// the check value of CRC-24/OpenPGP
+	if got := updateCRC(crcInit, []byte("123456789")); got != 0x21CF02 {
+		t.Errorf("crc mismatch: got=%06X want=%06X", got, 0x21CF02)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := make([]byte, 1000)
+	_, _ = rand.Read(data)
+
+	text := armored(t, data)
+
+	jsonEscaped := `"` + strings.ReplaceAll(text, "\n", `\n`) + `"`
+	quoted := "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ") + "\n"
+	indented := "\n\n```\n  " + strings.ReplaceAll(text, "\n", "\r\n  ") + "```\n\n"
+	rewrapped := strings.Replace(text, "\n", "", 2)
+	rewrapped = BeginLine + "\n" + rewrapped[len(BeginLine):]
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "plain", text: text},
+		{name: "json", text: jsonEscaped},
+		{name: "quoted", text: quoted},
+		{name: "indented", text: indented},
+		{name: "rewrapped", text: rewrapped},
+		{name: "trailing", text: text + "\nthanks!\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Detect(bufio.NewReader(strings.NewReader(test.text)))
+			if err != nil || !ok {
+				t.Errorf("not detected: %v", err)
+			}
+
+			got, err := io.ReadAll(NewReader(strings.NewReader(test.text)))
+			if err != nil {
+				t.Errorf("failed to read: %v", err)
+				return
+			}
+
+			if !bytes.Equal(got, data) {
+				t.Errorf("data mismatch")
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	text := armored(t, []byte("some encrypted data"))
+	lines := strings.Split(text, "\n")
+
+	tests := []struct {
+		name   string
+		text   string
+		expErr error
+	}{
+		{name: "empty", text: "", expErr: ErrorNoBegin},
+		{name: "no-begin", text: "hello\n" + text, expErr: ErrorNoBegin},
+		{name: "truncated", text: strings.Join(lines[:2], "\n"), expErr: ErrorNoEnd},
+		{name: "damaged", text: strings.Replace(text, "c29t", "c29u", 1), expErr: ErrorChecksum},
+		{name: "no-checksum", text: strings.Join([]string{lines[0], lines[1], lines[3]}, "\n"), expErr: ErrorInvalid},
+		{name: "invalid", text: strings.Replace(text, "c29t", "c2*t", 1), expErr: ErrorInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := io.ReadAll(NewReader(strings.NewReader(test.text)))
+			if !errors.Is(err, test.expErr) {
+				t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestIsArmored(t *testing.T) {
+	tests := []struct {
+		data string
+		exp  bool
+	}{
+		{data: BeginLine, exp: true},
+		{data: " \n\t\"" + BeginLine, exp: true},
+		{data: "text\n" + BeginLine, exp: false},
+		{data: "FENC", exp: false},
+	}
+
+	for _, test := range tests {
+		if got := IsArmored([]byte(test.data)); got != test.exp {
+			t.Errorf("armored mismatch for %q: got=%t want=%t", test.data, got, test.exp)
+		}
+	}
+}
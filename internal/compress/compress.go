@@ -0,0 +1,211 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	IDNone = 0
+	IDGzip = 1
+	IDZstd = 2
+)
+
+// Method describes a compression method. The ID is stored in the header of encrypted files.
+// The Level is used only for compression, decompression doesn't need it.
+type Method struct {
+	ID    uint8
+	Name  string
+	Level int
+	Auto  bool
+}
+
+var ErrorUnsupportedCompression = errors.New("unsupported compression")
+
+// FromName returns the compression method for the name. Supported names are
+// none, gzip, gzip:1 to gzip:9, zstd and auto. The auto method must be resolved
+// with a call to Choose before it can be used.
+func FromName(name string) (m Method, err error) {
+	method, level, hasLevel := strings.Cut(name, ":")
+
+	switch method {
+	case "none":
+		m = Method{ID: IDNone, Name: method}
+	case "gzip":
+		m = Method{ID: IDGzip, Name: method, Level: gzip.DefaultCompression}
+	case "zstd":
+		m = Method{ID: IDZstd, Name: method, Level: int(zstd.SpeedDefault)}
+	case "auto":
+		m = Method{Name: method, Auto: true}
+	default:
+		return Method{}, ErrorUnsupportedCompression
+	}
+
+	if !hasLevel {
+		return m, nil
+	}
+
+	if m.ID != IDGzip || m.Auto {
+		return Method{}, ErrorUnsupportedCompression
+	}
+
+	m.Level, err = strconv.Atoi(level)
+	if err != nil || m.Level < gzip.BestSpeed || m.Level > gzip.BestCompression {
+		return Method{}, ErrorUnsupportedCompression
+	}
+
+	return m, nil
+}
+
+// FromID returns the compression method stored in the header of an encrypted file.
+func FromID(id uint8) (m Method, err error) {
+	switch id {
+	case IDNone:
+		m = Method{ID: id, Name: "none"}
+	case IDGzip:
+		m = Method{ID: id, Name: "gzip", Level: gzip.DefaultCompression}
+	case IDZstd:
+		m = Method{ID: id, Name: "zstd", Level: int(zstd.SpeedDefault)}
+	default:
+		err = ErrorUnsupportedCompression
+	}
+
+	return
+}
+
+// NewWriter returns a writer that compresses data written to it and sends it to w.
+// Closing the returned writer doesn't close w.
+func (m Method) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch m.ID {
+	case IDNone:
+		return nopWriteCloser{w}, nil
+	case IDGzip:
+		return gzip.NewWriterLevel(w, m.Level)
+	case IDZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevel(m.Level)), zstd.WithEncoderConcurrency(1))
+	}
+
+	return nil, ErrorUnsupportedCompression
+}
+
+// NewReader returns a reader that decompresses data read from r.
+func (m Method) NewReader(r io.Reader) (io.ReadCloser, error) {
+	switch m.ID {
+	case IDNone:
+		return io.NopCloser(r), nil
+	case IDGzip:
+		gunzipper, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		gunzipper.Multistream(false)
+
+		return gunzipper, nil
+	case IDZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	}
+
+	return nil, ErrorUnsupportedCompression
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// SampleSize is the number of bytes from the start of the input that Choose needs.
+const SampleSize = 64 << 10
+
+// maxEntropy is the number of bits per byte above which the data is considered incompressible.
+const maxEntropy = 7.5
+
+// Choose resolves the auto compression method by looking at the sample from the start of the input.
+// Data that is already compressed (has a known signature of a compressed format or looks random)
+// is not compressed again. Other methods are returned unchanged.
+func (m Method) Choose(sample []byte) Method {
+	if !m.Auto {
+		return m
+	}
+
+	if isCompressedFormat(sample) || entropy(sample) > maxEntropy {
+		none, _ := FromID(IDNone)
+		return none
+	}
+
+	gz, _ := FromID(IDGzip)
+	return gz
+}
+
+var compressedSignatures = [][]byte{
+	{0x1f, 0x8b},                       // gzip
+	{0x28, 0xb5, 0x2f, 0xfd},           // zstd
+	{'B', 'Z', 'h'},                    // bzip2
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},   // xz
+	{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, // 7z
+	{'P', 'K', 0x03, 0x04},             // zip, docx, jar...
+	{0xff, 0xd8, 0xff},                 // jpeg
+	{0x89, 'P', 'N', 'G'},              // png
+	{'G', 'I', 'F', '8'},               // gif
+	{'f', 'L', 'a', 'C'},               // flac
+	{'O', 'g', 'g', 'S'},               // ogg
+	{'I', 'D', '3'},                    // mp3
+	{0x1a, 0x45, 0xdf, 0xa3},           // mkv, webm
+	{'R', 'a', 'r', '!', 0x1a, 0x07},   // rar
+	{0x04, 0x22, 0x4d, 0x18},           // lz4
+	{0x00, 0x00, 0x00, 0x0c, 'j', 'P'}, // jpeg 2000
+	{0x00, 0x00, 0x01, 0xba},           // mpeg
+}
+
+func isCompressedFormat(sample []byte) bool {
+	for _, signature := range compressedSignatures {
+		if bytes.HasPrefix(sample, signature) {
+			return true
+		}
+	}
+
+	// mp4, mov, heic...
+	if len(sample) >= 8 && string(sample[4:8]) == "ftyp" {
+		return true
+	}
+
+	return false
+}
+
+// entropy returns Shannon entropy of the data in bits per byte.
+func entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	var e float64
+	n := float64(len(data))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+
+		p := float64(c) / n
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
@@ -0,0 +1,118 @@
+package compress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFromName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expErr   error
+		expID    uint8
+		expLevel int
+	}{
+		{name: "none", expID: IDNone},
+		{name: "gzip", expID: IDGzip, expLevel: -1},
+		{name: "gzip:1", expID: IDGzip, expLevel: 1},
+		{name: "gzip:9", expID: IDGzip, expLevel: 9},
+		{name: "gzip:0", expErr: ErrorUnsupportedCompression},
+		{name: "gzip:x", expErr: ErrorUnsupportedCompression},
+		{name: "zstd", expID: IDZstd, expLevel: 2},
+		{name: "zstd:3", expErr: ErrorUnsupportedCompression},
+		{name: "lzma", expErr: ErrorUnsupportedCompression},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := FromName(test.name)
+			if got, want := err, test.expErr; got != want {
+				t.Errorf("error mismatch: got=%v want=%v", got, want)
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got, want := m.ID, test.expID; got != want {
+				t.Errorf("id mismatch: got=%d want=%d", got, want)
+			}
+
+			if got, want := m.Level, test.expLevel; got != want {
+				t.Errorf("level mismatch: got=%d want=%d", got, want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := strings.Repeat("Lorem ipsum dolor sit amet. ", 1000)
+
+	for _, name := range []string{"none", "gzip", "gzip:1", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			m, _ := FromName(name)
+
+			buffer := bytes.NewBuffer(nil)
+
+			w, err := m.NewWriter(buffer)
+			if err != nil {
+				t.Errorf("failed to create writer: %v", err)
+				return
+			}
+
+			_, _ = w.Write([]byte(data))
+
+			if err = w.Close(); err != nil {
+				t.Errorf("failed to close writer: %v", err)
+				return
+			}
+
+			r, err := m.NewReader(buffer)
+			if err != nil {
+				t.Errorf("failed to create reader: %v", err)
+				return
+			}
+
+			result, err := io.ReadAll(r)
+			if err != nil {
+				t.Errorf("failed to read: %v", err)
+				return
+			}
+
+			if got, want := string(result), data; got != want {
+				t.Error("data mismatch")
+			}
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	random := make([]byte, SampleSize)
+	_, _ = rand.Read(random)
+
+	tests := []struct {
+		name   string
+		sample []byte
+		expID  uint8
+	}{
+		{name: "empty", sample: nil, expID: IDGzip},
+		{name: "text", sample: []byte(strings.Repeat("Lorem ipsum dolor sit amet. ", 1000)), expID: IDGzip},
+		{name: "random", sample: random, expID: IDNone},
+		{name: "jpeg", sample: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), expID: IDNone},
+		{name: "mp4", sample: []byte("\x00\x00\x00\x20ftypisom"), expID: IDNone},
+	}
+
+	auto, _ := FromName("auto")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, want := auto.Choose(test.sample).ID, test.expID; got != want {
+				t.Errorf("id mismatch: got=%d want=%d", got, want)
+			}
+		})
+	}
+}
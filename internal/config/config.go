@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+// EnvPath is the environment variable with the path of the config file, which overrides the default path.
+const EnvPath = "FENC_CONFIG"
+
+const profilesKey = "profiles"
+
+var ErrorUnknownProfile = errors.New("unknown profile")
+
+// Config holds the settings from the config file: the defaults, which apply to all invocations,
+// and named profiles, which are applied over the defaults. Each setting can have multiple values.
+type Config struct {
+	Path     string
+	Defaults map[string][]string
+	Profiles map[string]map[string][]string
+}
+
+// Value is the effective value of a setting, with its source: "config" or "profile <name>".
+type Value struct {
+	Values []string
+	Source string
+}
+
+// Path returns the path of the config file: the one from the environment variable or the default one.
+// It reports whether the path is from the environment variable.
+func Path() (string, bool, error) {
+	if path := os.Getenv(EnvPath); path != "" {
+		return path, true, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to find config directory: %w", err)
+	}
+
+	return filepath.Join(dir, values.AppName, "config.toml"), false, nil
+}
+
+// Load reads the config file. A missing file at the default path gives an empty config,
+// but the file provided with the environment variable must exist.
+func Load() (*Config, error) {
+	path, explicit, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return &Config{Path: path}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	c, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("config file %q: %w", path, err)
+	}
+
+	c.Path = path
+
+	return c, nil
+}
+
+// Parse parses the config in TOML format. Top level keys are the defaults and
+// the tables under "profiles" are the named profiles.
+func Parse(data string) (*Config, error) {
+	var raw map[string]any
+
+	if _, err := toml.Decode(data, &raw); err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		Defaults: map[string][]string{},
+		Profiles: map[string]map[string][]string{},
+	}
+
+	for key, value := range raw {
+		if key != profilesKey {
+			values, err := settingValues(value)
+			if err != nil {
+				return nil, fmt.Errorf("setting %q: %w", key, err)
+			}
+
+			c.Defaults[key] = values
+			continue
+		}
+
+		profiles, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a table", profilesKey)
+		}
+
+		for name, profileValue := range profiles {
+			profile, ok := profileValue.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("profile %q must be a table", name)
+			}
+
+			settings := map[string][]string{}
+			for key, value := range profile {
+				values, err := settingValues(value)
+				if err != nil {
+					return nil, fmt.Errorf("profile %q, setting %q: %w", name, key, err)
+				}
+
+				settings[key] = values
+			}
+
+			c.Profiles[name] = settings
+		}
+	}
+
+	return c, nil
+}
+
+// Settings returns the effective settings for the profile, which can be empty for defaults only.
+// The conflicts map a setting to the settings that can't be used together with it:
+// a setting of the profile displaces the conflicting settings of the defaults.
+func (c *Config) Settings(profile string, conflicts map[string][]string) (map[string]Value, error) {
+	settings := map[string]Value{}
+
+	for key, values := range c.Defaults {
+		settings[key] = Value{Values: values, Source: "config"}
+	}
+
+	if profile == "" {
+		return settings, nil
+	}
+
+	profileSettings, ok := c.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrorUnknownProfile, profile)
+	}
+
+	for key := range profileSettings {
+		for _, conflict := range conflicts[key] {
+			if _, inProfile := profileSettings[conflict]; !inProfile {
+				delete(settings, conflict)
+			}
+		}
+	}
+
+	for key, values := range profileSettings {
+		settings[key] = Value{Values: values, Source: "profile " + profile}
+	}
+
+	return settings, nil
+}
+
+// ProfileNames returns the sorted names of all profiles.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func settingValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("array items must be strings")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
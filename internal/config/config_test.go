@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+const testConfig = `
+hash = "sha512"
+keep = true
+exclude = ["*.tmp", "*.log"]
+
+[profiles.backups]
+out-dir = "/backup"
+collision = "skip"
+hash = "sha256"
+
+[profiles.quiet]
+quiet = true
+`
+
+const testConflictsConfig = `
+key-env = "FENC_HOME_KEY"
+
+[profiles.work]
+key = "~/.fenc/work.key"
+
+[profiles.both]
+key = "~/.fenc/both.key"
+key-env = "FENC_BOTH_KEY"
+
+[profiles.env]
+key-env = "FENC_ENV_KEY"
+`
+
+var testConflicts = map[string][]string{
+	"key":     {"key-env"},
+	"key-env": {"key"},
+}
+
+func TestSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		exp     map[string]Value
+		expErr  error
+	}{
+		{
+			name:    "defaults",
+			profile: "",
+			exp: map[string]Value{
+				"hash":    {Values: []string{"sha512"}, Source: "config"},
+				"keep":    {Values: []string{"true"}, Source: "config"},
+				"exclude": {Values: []string{"*.tmp", "*.log"}, Source: "config"},
+			},
+		},
+		{
+			name:    "profile",
+			profile: "backups",
+			exp: map[string]Value{
+				"hash":      {Values: []string{"sha256"}, Source: "profile backups"},
+				"keep":      {Values: []string{"true"}, Source: "config"},
+				"exclude":   {Values: []string{"*.tmp", "*.log"}, Source: "config"},
+				"out-dir":   {Values: []string{"/backup"}, Source: "profile backups"},
+				"collision": {Values: []string{"skip"}, Source: "profile backups"},
+			},
+		},
+		{
+			name:    "unknown_profile",
+			profile: "none",
+			expErr:  ErrorUnknownProfile,
+		},
+	}
+
+	c, err := Parse(testConfig)
+	if err != nil {
+		t.Errorf("failed to parse config: %v", err)
+		return
+	}
+
+	if got, want := c.ProfileNames(), []string{"backups", "quiet"}; !slices.Equal(got, want) {
+		t.Errorf("profiles mismatch: got=%v want=%v", got, want)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings, err := c.Settings(test.profile, testConflicts)
+			if got, want := err, test.expErr; !errors.Is(got, want) {
+				t.Errorf("error mismatch: got=%v want=%v", got, want)
+				return
+			}
+
+			equal := maps.EqualFunc(settings, test.exp, func(a, b Value) bool {
+				return a.Source == b.Source && slices.Equal(a.Values, b.Values)
+			})
+			if !equal {
+				t.Errorf("settings mismatch: got=%v want=%v", settings, test.exp)
+			}
+		})
+	}
+}
+
+func TestSettingsConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		exp     map[string]Value
+	}{
+		{
+			name:    "defaults",
+			config:  testConflictsConfig,
+			profile: "",
+			exp: map[string]Value{
+				"key-env": {Values: []string{"FENC_HOME_KEY"}, Source: "config"},
+			},
+		},
+		{
+			name:    "profile_key_displaces_default_key_env",
+			config:  testConflictsConfig,
+			profile: "work",
+			exp: map[string]Value{
+				"key": {Values: []string{"~/.fenc/work.key"}, Source: "profile work"},
+			},
+		},
+		{
+			name:    "profile_key_env_displaces_default_key",
+			config:  "key = \"~/.fenc/home.key\"\n[profiles.env]\nkey-env = \"FENC_ENV_KEY\"\n",
+			profile: "env",
+			exp: map[string]Value{
+				"key-env": {Values: []string{"FENC_ENV_KEY"}, Source: "profile env"},
+			},
+		},
+		{
+			name:    "profile_overrides_same_setting",
+			config:  testConflictsConfig,
+			profile: "env",
+			exp: map[string]Value{
+				"key-env": {Values: []string{"FENC_ENV_KEY"}, Source: "profile env"},
+			},
+		},
+		{
+			name:    "conflict_within_profile_kept",
+			config:  testConflictsConfig,
+			profile: "both",
+			exp: map[string]Value{
+				"key":     {Values: []string{"~/.fenc/both.key"}, Source: "profile both"},
+				"key-env": {Values: []string{"FENC_BOTH_KEY"}, Source: "profile both"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := Parse(test.config)
+			if err != nil {
+				t.Errorf("failed to parse config: %v", err)
+				return
+			}
+
+			settings, err := c.Settings(test.profile, testConflicts)
+			if err != nil {
+				t.Errorf("failed with error: %v", err)
+				return
+			}
+
+			equal := maps.EqualFunc(settings, test.exp, func(a, b Value) bool {
+				return a.Source == b.Source && slices.Equal(a.Values, b.Values)
+			})
+			if !equal {
+				t.Errorf("settings mismatch: got=%v want=%v", settings, test.exp)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		`hash = `,
+		`pad = 1.5`,
+		`profiles = "x"`,
+		"[profiles]\nx = 1",
+		"exclude = [1, 2]",
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("parse succeeded for invalid config: %q", data)
+		}
+	}
+}
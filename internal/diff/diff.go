@@ -0,0 +1,246 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single step of the edit script. The a and b fields are the positions in the old and the new lines.
+type op struct {
+	kind opKind
+	a, b int
+}
+
+// Unified returns the unified diff of the old and the new content, with the provided number of context lines.
+// It returns an empty string if the contents are equal.
+func Unified(oldName, newName string, oldData, newData []byte, context int) string {
+	a := splitLines(oldData)
+	b := splitLines(newData)
+
+	ops := edits(a, b)
+
+	var sb strings.Builder
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+		}
+
+		// changes separated by at most 2*context equal lines belong to the same hunk
+		last := i
+		for j := i; j < len(ops) && j-last <= 2*context+1; j++ {
+			if ops[j].kind != opEqual {
+				last = j
+			}
+		}
+
+		start := max(i-context, 0)
+		stop := min(last+context+1, len(ops))
+
+		writeHunk(&sb, a, b, ops[start:stop])
+
+		i = stop
+	}
+
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, a, b []string, ops []op) {
+	var countA, countB int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			countA++
+		}
+		if o.kind != opDelete {
+			countB++
+		}
+	}
+
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(ops[0].a, countA), hunkRange(ops[0].b, countB))
+
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			writeLine(sb, ' ', a[o.a])
+		case opDelete:
+			writeLine(sb, '-', a[o.a])
+		case opInsert:
+			writeLine(sb, '+', b[o.b])
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits the data into lines, keeping the line terminators.
+func splitLines(data []byte) []string {
+	var lines []string
+
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+
+	return lines
+}
+
+// edits returns the shortest edit script that turns a into b, using the linear space variant of the Myers
+// algorithm: the middle snake of an optimal path splits the problem in two, which are solved recursively.
+// It needs O(n+m) memory, instead of O((n+m)*d) of keeping the frontier of every step.
+func edits(a, b []string) []op {
+	size := 2*(len(a)+len(b)) + 2
+	e := &editor{a: a, b: b, vf: make([]int, size), vb: make([]int, size)}
+
+	e.compare(0, len(a), 0, len(b))
+
+	return e.ops
+}
+
+type editor struct {
+	a, b   []string
+	vf, vb []int // the furthest reaching paths on diagonals, forward and backward, reused by all calls
+	ops    []op
+}
+
+// compare appends the edit script of a[aLo:aHi] and b[bLo:bHi] to the ops.
+func (e *editor) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && e.a[aLo] == e.b[bLo] {
+		e.ops = append(e.ops, op{kind: opEqual, a: aLo, b: bLo})
+		aLo++
+		bLo++
+	}
+
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && e.a[aHi-suffix-1] == e.b[bHi-suffix-1] {
+		suffix++
+	}
+
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			e.ops = append(e.ops, op{kind: opInsert, a: aLo, b: y})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			e.ops = append(e.ops, op{kind: opDelete, a: x, b: bLo})
+		}
+	default:
+		// both parts differ at the start and at the end, so the edit distance is at least 2
+		// and both halves are smaller than the whole
+		x, y, u, v := e.middleSnake(aLo, aHi, bLo, bHi)
+
+		e.compare(aLo, x, bLo, y)
+
+		for ; x < u; x, y = x+1, y+1 {
+			e.ops = append(e.ops, op{kind: opEqual, a: x, b: y})
+		}
+
+		e.compare(u, aHi, v, bHi)
+	}
+
+	for i := range suffix {
+		e.ops = append(e.ops, op{kind: opEqual, a: aHi + i, b: bHi + i})
+	}
+}
+
+// middleSnake returns the snake, from (x, y) to (u, v), in the middle of an optimal path
+// from (aLo, bLo) to (aHi, bHi). The paths are searched from both ends until they overlap.
+func (e *editor) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+
+	// the diagonal k is at the index offset+k; the backward diagonals are those of the reversed parts,
+	// so the backward diagonal delta-k meets the forward diagonal k
+	offset := (n+m+1)/2 + 1
+	vf, vb := e.vf[:2*offset+1], e.vb[:2*offset+1]
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for d := 0; d <= (n+m+1)/2; d++ {
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				px = vf[offset+k+1]
+			} else {
+				px = vf[offset+k-1] + 1
+			}
+
+			py := px - k
+			sx, sy := px, py
+			for sx < n && sy < m && e.a[aLo+sx] == e.b[bLo+sy] {
+				sx++
+				sy++
+			}
+
+			vf[offset+k] = sx
+
+			if kr := delta - k; odd && kr >= -(d-1) && kr <= d-1 && sx+vb[offset+kr] >= n {
+				return aLo + px, bLo + py, aLo + sx, bLo + sy
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				px = vb[offset+k+1]
+			} else {
+				px = vb[offset+k-1] + 1
+			}
+
+			py := px - k
+			sx, sy := px, py
+			for sx < n && sy < m && e.a[aHi-1-sx] == e.b[bHi-1-sy] {
+				sx++
+				sy++
+			}
+
+			vb[offset+k] = sx
+
+			if kf := delta - k; !odd && kf >= -d && kf <= d && sx+vf[offset+kf] >= n {
+				return aHi - sx, bHi - sy, aHi - px, bHi - py
+			}
+		}
+	}
+
+	panic("diff: no middle snake") // unreachable, the paths always meet
+}
@@ -0,0 +1,192 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldData string
+		newData string
+		context int
+		exp     string
+	}{
+		{
+			name:    "equal",
+			oldData: "a\nb\n",
+			newData: "a\nb\n",
+			context: 3,
+			exp:     "",
+		},
+		{
+			name:    "both_empty",
+			oldData: "",
+			newData: "",
+			context: 3,
+			exp:     "",
+		},
+		{
+			name:    "added_file",
+			oldData: "",
+			newData: "a\nb\n",
+			context: 3,
+			exp:     "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "change_in_middle",
+			oldData: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			newData: "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			context: 1,
+			exp:     "--- old\n+++ new\n@@ -4,3 +4,3 @@\n 4\n-5\n+five\n 6\n",
+		},
+		{
+			name:    "two_hunks",
+			oldData: "1\n2\n3\n4\n5\n6\n7\n",
+			newData: "0\n1\n2\n3\n4\n5\n7\n",
+			context: 1,
+			exp:     "--- old\n+++ new\n@@ -1 +1,2 @@\n+0\n 1\n@@ -5,3 +6,2 @@\n 5\n-6\n 7\n",
+		},
+		{
+			name:    "joined_hunks",
+			oldData: "1\n2\n3\n4\n",
+			newData: "one\n2\n3\nfour\n",
+			context: 1,
+			exp:     "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n-4\n+four\n",
+		},
+		{
+			name:    "no_newline_at_end",
+			oldData: "a\nb",
+			newData: "a\nb\n",
+			context: 3,
+			exp:     "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Unified("old", "new", []byte(test.oldData), []byte(test.newData), test.context)
+			if want := test.exp; got != want {
+				t.Errorf("diff mismatch:\ngot=\n%s\nwant=\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestEdits(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+
+	for i := range 500 {
+		a := randomLines(rnd, rnd.IntN(20), 4)
+		b := randomLines(rnd, rnd.IntN(20), 4)
+
+		ops := edits(a, b)
+
+		checkEdits(t, a, b, ops)
+
+		if got, want := countEqual(ops), lcsLength(a, b); got != want {
+			t.Errorf("case %d: not the shortest edit script: got=%d equal lines want=%d", i, got, want)
+		}
+	}
+}
+
+func TestEditsLarge(t *testing.T) {
+	const size = 5000
+
+	a := make([]string, size)
+	b := make([]string, size)
+	for i := range size {
+		a[i] = fmt.Sprintf("old line %d", i)
+		b[i] = fmt.Sprintf("new line %d", i)
+	}
+
+	b[size/2] = a[size/3] // a single common line
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+	ops := edits(a, b)
+	runtime.ReadMemStats(&after)
+
+	checkEdits(t, a, b, ops)
+
+	if got, want := countEqual(ops), 1; got != want {
+		t.Errorf("equal lines mismatch: got=%d want=%d", got, want)
+	}
+
+	// the edit distance is almost 2*size, keeping the frontier of every step would take gigabytes
+	if allocated, limit := after.TotalAlloc-before.TotalAlloc, uint64(16<<20); allocated > limit {
+		t.Errorf("too much memory allocated: got=%d limit=%d", allocated, limit)
+	}
+}
+
+func randomLines(rnd *rand.Rand, n, alphabet int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = string(rune('a' + rnd.IntN(alphabet)))
+	}
+
+	return lines
+}
+
+// checkEdits checks that the edit script turns a into b.
+func checkEdits(t *testing.T, a, b []string, ops []op) {
+	t.Helper()
+
+	var x, y int
+	for _, o := range ops {
+		if o.a != x || o.b != y {
+			t.Fatalf("position mismatch: got=(%d,%d) want=(%d,%d)", o.a, o.b, x, y)
+		}
+
+		switch o.kind {
+		case opEqual:
+			if a[x] != b[y] {
+				t.Fatalf("equal lines differ: %q %q", a[x], b[y])
+			}
+			x++
+			y++
+		case opDelete:
+			x++
+		case opInsert:
+			y++
+		}
+	}
+
+	if x != len(a) || y != len(b) {
+		t.Fatalf("incomplete edit script: got=(%d,%d) want=(%d,%d)", x, y, len(a), len(b))
+	}
+}
+
+func countEqual(ops []op) int {
+	count := 0
+	for _, o := range ops {
+		if o.kind == opEqual {
+			count++
+		}
+	}
+
+	return count
+}
+
+func lcsLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+
+	return dp[0][0]
+}
@@ -0,0 +1,167 @@
+package dotenv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Var is a single variable definition.
+type Var struct {
+	Name  string
+	Value string
+}
+
+var (
+	ErrorMissingEquals   = errors.New("missing '='")
+	ErrorInvalidName     = errors.New("invalid variable name")
+	ErrorUnclosedQuote   = errors.New("unclosed quote")
+	ErrorTrailingGarbage = errors.New("unexpected characters after closing quote")
+)
+
+// Parse parses the content in dotenv format, one "NAME=value" definition per line.
+// Empty lines and lines starting with '#' are ignored, as is the "export " prefix.
+// Values in single quotes are taken literally. Values in double quotes can span multiple lines
+// and support the escape sequences \n, \r, \t, \" and \\. Unquoted values are trimmed
+// and end at " #", which starts a comment.
+func Parse(r io.Reader) ([]Var, error) {
+	var vars []Var
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		startLine := lineNum
+
+		line := strings.TrimSpace(strings.TrimSuffix(scanner.Text(), "\r"))
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %w", startLine, ErrorMissingEquals)
+		}
+
+		name = strings.TrimSpace(name)
+		if !validName(name) {
+			return nil, fmt.Errorf("line %d: %w: %q", startLine, ErrorInvalidName, name)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: %w", startLine, ErrorUnclosedQuote)
+			}
+
+			if !onlyComment(value[end+2:]) {
+				return nil, fmt.Errorf("line %d: %w", startLine, ErrorTrailingGarbage)
+			}
+
+			value = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			var sb strings.Builder
+
+			rest := value[1:]
+			for {
+				end, closed := unescape(&sb, rest)
+				if closed {
+					rest = rest[end:]
+					break
+				}
+
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: %w", startLine, ErrorUnclosedQuote)
+				}
+
+				lineNum++
+				sb.WriteByte('\n')
+				rest = strings.TrimSuffix(scanner.Text(), "\r")
+			}
+
+			if !onlyComment(rest) {
+				return nil, fmt.Errorf("line %d: %w", startLine, ErrorTrailingGarbage)
+			}
+
+			value = sb.String()
+
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = value[:idx]
+			}
+
+			value = strings.TrimSpace(value)
+		}
+
+		vars = append(vars, Var{Name: name, Value: value})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// unescape writes the content of s up to the closing double quote into sb, processing escape sequences.
+// It returns the index after the closing quote and whether the quote was found.
+func unescape(sb *strings.Builder, s string) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return i + 1, true
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\':
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return len(s), false
+}
+
+func onlyComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) && !(c == '.' && i > 0) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package dotenv
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		exp    []Var
+		expErr error
+	}{
+		{
+			name: "empty",
+			data: "",
+			exp:  nil,
+		},
+		{
+			name: "simple",
+			data: "# comment\n\nA=1\r\nexport B = two words \nC=\n",
+			exp:  []Var{{"A", "1"}, {"B", "two words"}, {"C", ""}},
+		},
+		{
+			name: "comments",
+			data: "A=x#y # comment\nB='a # b' # comment\nC=\"c\" # comment\n",
+			exp:  []Var{{"A", "x#y"}, {"B", "a # b"}, {"C", "c"}},
+		},
+		{
+			name: "single_quotes",
+			data: `A='it\n"is"'`,
+			exp:  []Var{{"A", `it\n"is"`}},
+		},
+		{
+			name: "double_quotes",
+			data: `A="line1\nline2\t\"q\" \\ \$"`,
+			exp:  []Var{{"A", "line1\nline2\t\"q\" \\ \\$"}},
+		},
+		{
+			name: "multiline",
+			data: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nB=2\n",
+			exp:  []Var{{"KEY", "-----BEGIN-----\nabc\n-----END-----"}, {"B", "2"}},
+		},
+		{
+			name:   "missing_equals",
+			data:   "A=1\nB\n",
+			expErr: ErrorMissingEquals,
+		},
+		{
+			name:   "invalid_name",
+			data:   "1A=1\n",
+			expErr: ErrorInvalidName,
+		},
+		{
+			name:   "unclosed_quote",
+			data:   "A=\"abc\nB=1\n",
+			expErr: ErrorUnclosedQuote,
+		},
+		{
+			name:   "trailing_garbage",
+			data:   "A='abc'def\n",
+			expErr: ErrorTrailingGarbage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars, err := Parse(strings.NewReader(test.data))
+			if got, want := err, test.expErr; !errors.Is(got, want) {
+				t.Errorf("error mismatch: got=%v want=%v", got, want)
+				return
+			}
+
+			if got, want := vars, test.exp; !slices.Equal(got, want) {
+				t.Errorf("vars mismatch: got=%q want=%q", got, want)
+			}
+		})
+	}
+}
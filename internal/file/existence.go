@@ -38,3 +38,19 @@ func MustNotExist(fileName string) error {
 
 	return fmt.Errorf("file already exist: %q", fileName)
 }
+
+// Exists reports whether the file exists. It fails if the file can't be accessed or if it is a directory.
+func Exists(fileName string) (bool, error) {
+	fileInfo, err := os.Stat(fileName)
+	if err != nil && os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to access file %q: %w", fileName, err)
+	}
+
+	if fileInfo.IsDir() {
+		return false, fmt.Errorf("file is a directory: %q", fileName)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReadList reads a list of file names separated by the separator byte, usually '\n' or 0.
+// Empty entries are ignored. For newline separated lists, a trailing '\r' is removed from each entry.
+func ReadList(r io.Reader, separator byte) ([]string, error) {
+	var list []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if i := bytes.IndexByte(data, separator); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	})
+
+	for scanner.Scan() {
+		entry := scanner.Bytes()
+		if separator == '\n' {
+			entry = bytes.TrimSuffix(entry, []byte{'\r'})
+		}
+
+		if len(entry) == 0 {
+			continue
+		}
+
+		list = append(list, string(entry))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
+	}
+
+	return list, nil
+}
+
+// ReadListFile reads a list of file names from the file. If the file name is "-", the list is read from stdin.
+func ReadListFile(fileName string, separator byte) (list []string, err error) {
+	if fileName == "-" {
+		return ReadList(os.Stdin, separator)
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file list %q: %w", fileName, err)
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("failed to close file list %q: %w", fileName, errClose)
+		}
+	}()
+
+	return ReadList(f, separator)
+}
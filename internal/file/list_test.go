@@ -0,0 +1,55 @@
+package file
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadList(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		separator byte
+		exp       []string
+	}{
+		{
+			name:      "empty",
+			data:      "",
+			separator: '\n',
+			exp:       nil,
+		},
+		{
+			name:      "lines",
+			data:      "a.txt\nb c.txt\n\nd/e.txt\n",
+			separator: '\n',
+			exp:       []string{"a.txt", "b c.txt", "d/e.txt"},
+		},
+		{
+			name:      "lines_crlf_no_final_newline",
+			data:      "a.txt\r\nb.txt",
+			separator: '\n',
+			exp:       []string{"a.txt", "b.txt"},
+		},
+		{
+			name:      "null",
+			data:      "a.txt\x00new\nline.txt\x00\x00c.txt\x00",
+			separator: 0,
+			exp:       []string{"a.txt", "new\nline.txt", "c.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list, err := ReadList(strings.NewReader(test.data), test.separator)
+			if err != nil {
+				t.Errorf("failed with error: %v", err)
+				return
+			}
+
+			if got, want := list, test.exp; !slices.Equal(got, want) {
+				t.Errorf("list mismatch: got=%q want=%q", got, want)
+			}
+		})
+	}
+}
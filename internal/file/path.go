@@ -0,0 +1,121 @@
+package file
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Relocate returns the path the file would have inside the directory dir.
+// Relative paths keep their directory structure. Absolute paths and paths
+// pointing outside the current directory keep only the base name.
+func Relocate(fileName, dir string) string {
+	rel := filepath.Clean(fileName)
+
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(rel)
+	}
+
+	return filepath.Join(dir, rel)
+}
+
+// Same reports whether the two file names point to the same path.
+func Same(fileName1, fileName2 string) bool {
+	abs1, err1 := filepath.Abs(fileName1)
+	abs2, err2 := filepath.Abs(fileName2)
+	if err1 != nil || err2 != nil {
+		return filepath.Clean(fileName1) == filepath.Clean(fileName2)
+	}
+
+	return abs1 == abs2
+}
+
+// Numbered returns the file name with the number n inserted before the extension,
+// for example "name (1).txt" for "name.txt".
+func Numbered(fileName string, n int) string {
+	ext := filepath.Ext(fileName)
+	if ext == fileName || strings.HasSuffix(fileName, string(filepath.Separator)+ext) {
+		ext = "" // a dot file, like ".env"
+	}
+
+	return strings.TrimSuffix(fileName, ext) + " (" + strconv.Itoa(n) + ")" + ext
+}
+
+// TempName returns a name of a new, empty file in the same directory as fileName.
+// The file is meant to be written and then renamed to fileName, so it gets the permissions of fileName,
+// if it exists, or the permissions of a new file, 0666 with the umask applied, otherwise.
+func TempName(fileName string) (string, error) {
+	perm, existing := os.FileMode(0o666), false
+	if fileInfo, err := os.Stat(fileName); err == nil {
+		perm, existing = fileInfo.Mode().Perm(), true
+	}
+
+	dir, base := filepath.Split(fileName)
+
+	for range 10000 {
+		tempName := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+
+		f, err := os.OpenFile(tempName, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("failed to create temporary file for %q: %w", fileName, err)
+		}
+
+		if err = f.Close(); err != nil {
+			return "", fmt.Errorf("failed to close temporary file %q: %w", tempName, err)
+		}
+
+		// the umask could have cleared some of the permissions of the existing file
+		if existing {
+			if err = os.Chmod(tempName, perm); err != nil {
+				_ = os.Remove(tempName)
+				return "", fmt.Errorf("failed to set permissions of %q: %w", tempName, err)
+			}
+		}
+
+		return tempName, nil
+	}
+
+	return "", fmt.Errorf("failed to create temporary file for %q: too many attempts", fileName)
+}
+
+// Replace replaces the file with the one produced by the write function. The function gets the name
+// of a temporary file in the same directory, which replaces the original only if the function succeeds.
+// The new file gets the permissions of the original.
+func Replace(fileName string, write func(tempName string) error) error {
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to access file %q: %w", fileName, err)
+	}
+
+	tempName, err := TempName(fileName)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		if err := write(tempName); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(tempName, fileInfo.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to set permissions of %q: %w", tempName, err)
+		}
+
+		if err := os.Rename(tempName, fileName); err != nil {
+			return fmt.Errorf("failed to replace %q: %w", fileName, err)
+		}
+
+		return nil
+	}()
+	if err != nil {
+		_ = os.Remove(tempName)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocate(t *testing.T) {
+	tests := []struct {
+		fileName string
+		dir      string
+		exp      string
+	}{
+		{fileName: "a.txt", dir: "out", exp: "out/a.txt"},
+		{fileName: "docs/a.txt", dir: "out", exp: "out/docs/a.txt"},
+		{fileName: "./docs/../a.txt", dir: "out", exp: "out/a.txt"},
+		{fileName: "/home/user/a.txt", dir: "out", exp: "out/a.txt"},
+		{fileName: "../a.txt", dir: "out", exp: "out/a.txt"},
+		{fileName: "../../docs/a.txt", dir: "/backup", exp: "/backup/a.txt"},
+		{fileName: "..a.txt", dir: "out", exp: "out/..a.txt"},
+		{fileName: ".env", dir: "out", exp: "out/.env"},
+	}
+
+	for _, test := range tests {
+		fileName, dir, exp := filepath.FromSlash(test.fileName), filepath.FromSlash(test.dir), filepath.FromSlash(test.exp)
+		if got := Relocate(fileName, dir); got != exp {
+			t.Errorf("relocated mismatch for %q in %q: got=%q want=%q", fileName, dir, got, exp)
+		}
+	}
+}
+
+func TestSame(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+
+	tests := []struct {
+		fileName1 string
+		fileName2 string
+		exp       bool
+	}{
+		{fileName1: "a.txt", fileName2: "a.txt", exp: true},
+		{fileName1: "a.txt", fileName2: "./a.txt", exp: true},
+		{fileName1: "docs/../a.txt", fileName2: "a.txt", exp: true},
+		{fileName1: filepath.Join(wd, "a.txt"), fileName2: "a.txt", exp: true},
+		{fileName1: "a.txt", fileName2: "b.txt", exp: false},
+		{fileName1: "a.txt", fileName2: "a.txt.fenc", exp: false},
+		{fileName1: "docs/a.txt", fileName2: "a.txt", exp: false},
+	}
+
+	for _, test := range tests {
+		if got := Same(test.fileName1, test.fileName2); got != test.exp {
+			t.Errorf("same mismatch for %q and %q: got=%t want=%t", test.fileName1, test.fileName2, got, test.exp)
+		}
+	}
+}
+
+func TestNumbered(t *testing.T) {
+	tests := []struct {
+		fileName string
+		n        int
+		exp      string
+	}{
+		{fileName: "name.txt", n: 1, exp: "name (1).txt"},
+		{fileName: "name.txt", n: 12, exp: "name (12).txt"},
+		{fileName: "name", n: 1, exp: "name (1)"},
+		{fileName: "name.tar.gz", n: 1, exp: "name.tar (1).gz"},
+		{fileName: "name (1).txt", n: 1, exp: "name (1) (1).txt"},
+		{fileName: "name.txt.fenc", n: 2, exp: "name.txt (2).fenc"},
+		{fileName: ".fenc", n: 1, exp: ".fenc (1)"},
+		{fileName: ".env", n: 1, exp: ".env (1)"},
+		{fileName: ".env.fenc", n: 1, exp: ".env (1).fenc"},
+		{fileName: "dir/.env", n: 1, exp: "dir/.env (1)"},
+		{fileName: "dir.d/.fenc", n: 1, exp: "dir.d/.fenc (1)"},
+		{fileName: "dir.d/name", n: 1, exp: "dir.d/name (1)"},
+		{fileName: "out/docs/name.txt", n: 3, exp: "out/docs/name (3).txt"},
+	}
+
+	for _, test := range tests {
+		fileName, exp := filepath.FromSlash(test.fileName), filepath.FromSlash(test.exp)
+		if got := Numbered(fileName, test.n); got != exp {
+			t.Errorf("numbered mismatch for %q and %d: got=%q want=%q", fileName, test.n, got, exp)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	dir := t.TempDir()
+
+	fileName := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(fileName, []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		exp      bool
+		expErr   bool
+	}{
+		{name: "file", fileName: fileName, exp: true},
+		{name: "missing", fileName: filepath.Join(dir, "b.txt"), exp: false},
+		{name: "missing_dir", fileName: filepath.Join(dir, "sub", "b.txt"), exp: false},
+		{name: "dir", fileName: dir, expErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exists, err := Exists(test.fileName)
+			if got, want := err != nil, test.expErr; got != want {
+				t.Errorf("error mismatch: got=%v want error=%t", err, want)
+				return
+			}
+
+			if got, want := exists, test.exp; got != want {
+				t.Errorf("exists mismatch: got=%t want=%t", got, want)
+			}
+		})
+	}
+}
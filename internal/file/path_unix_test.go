@@ -0,0 +1,72 @@
+//go:build unix
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTempName(t *testing.T) {
+	oldMask := unix.Umask(0o022)
+	defer unix.Umask(oldMask)
+
+	tests := []struct {
+		name     string
+		existing os.FileMode // 0 if the file doesn't exist
+		expPerm  os.FileMode
+	}{
+		{name: "new", expPerm: 0o644},
+		{name: "private", existing: 0o600, expPerm: 0o600},
+		{name: "shared", existing: 0o664, expPerm: 0o664},
+		{name: "executable", existing: 0o755, expPerm: 0o755},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "data.txt")
+
+			if test.existing != 0 {
+				if err := os.WriteFile(fileName, []byte("data"), test.existing); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+
+				if err := os.Chmod(fileName, test.existing); err != nil {
+					t.Fatalf("failed to set permissions: %v", err)
+				}
+			}
+
+			tempName, err := TempName(fileName)
+			if err != nil {
+				t.Errorf("failed with error: %v", err)
+				return
+			}
+
+			if got, want := filepath.Dir(tempName), filepath.Dir(fileName); got != want {
+				t.Errorf("directory mismatch: got=%s want=%s", got, want)
+			}
+
+			if base := filepath.Base(tempName); !strings.HasPrefix(base, ".data.txt.") || !strings.HasSuffix(base, ".tmp") {
+				t.Errorf("unexpected temporary name: %s", base)
+			}
+
+			fileInfo, err := os.Stat(tempName)
+			if err != nil {
+				t.Errorf("failed to access temporary file: %v", err)
+				return
+			}
+
+			if got, want := fileInfo.Mode().Perm(), test.expPerm; got != want {
+				t.Errorf("permissions mismatch: got=%v want=%v", got, want)
+			}
+
+			if fileInfo.Size() != 0 {
+				t.Errorf("temporary file not empty: %d", fileInfo.Size())
+			}
+		})
+	}
+}
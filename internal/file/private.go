@@ -0,0 +1,93 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// sharedMemoryDir is a memory backed file system, available on most Linux systems.
+const sharedMemoryDir = "/dev/shm"
+
+// PrivateDir creates a new directory accessible only by the current user, for files with sensitive content.
+// It's created in shared memory if available, so the content never reaches the disk.
+// The caller is responsible for removing the directory with WipeDir.
+func PrivateDir(pattern string) (string, error) {
+	base := os.TempDir()
+	if fileInfo, err := os.Stat(sharedMemoryDir); err == nil && fileInfo.IsDir() {
+		base = sharedMemoryDir
+	}
+
+	dir, err := os.MkdirTemp(base, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create private directory: %w", err)
+	}
+
+	if err = os.Chmod(dir, 0o700); err != nil {
+		_ = os.Remove(dir)
+		return "", fmt.Errorf("failed to set permissions of %q: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+// Wipe overwrites the content of the file with zeros and removes it.
+func Wipe(fileName string) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", fileName, err)
+	}
+
+	fileInfo, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, zeroReader{}, fileInfo.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+
+	errClose := f.Close()
+	if err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		_ = os.Remove(fileName)
+		return fmt.Errorf("failed to wipe %q: %w", fileName, err)
+	}
+
+	if err = os.Remove(fileName); err != nil {
+		return fmt.Errorf("failed to remove %q: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// WipeDir wipes all regular files in the directory and removes it.
+func WipeDir(dir string) error {
+	var errWipe error
+
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if err := Wipe(path); err != nil && errWipe == nil {
+				errWipe = err
+			}
+		}
+		return nil
+	})
+
+	if err := os.RemoveAll(dir); err != nil && errWipe == nil {
+		errWipe = fmt.Errorf("failed to remove %q: %w", dir, err)
+	}
+
+	return errWipe
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
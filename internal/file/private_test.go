@@ -0,0 +1,45 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrivateDir(t *testing.T) {
+	dir, err := PrivateDir("fenc-test-")
+	if err != nil {
+		t.Errorf("failed to create private directory: %v", err)
+		return
+	}
+
+	fileInfo, err := os.Stat(dir)
+	if err != nil {
+		t.Errorf("failed to access private directory: %v", err)
+		return
+	}
+
+	if got, want := fileInfo.Mode().Perm(), os.FileMode(0o700); got != want {
+		t.Errorf("permissions mismatch: got=%v want=%v", got, want)
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Errorf("failed to create subdirectory: %v", err)
+		return
+	}
+
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte("secret"), 0o600); err != nil {
+			t.Errorf("failed to write file: %v", err)
+			return
+		}
+	}
+
+	if err = WipeDir(dir); err != nil {
+		t.Errorf("failed to wipe private directory: %v", err)
+	}
+
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("private directory not removed: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Filter decides which files should be processed, using patterns with gitignore-like semantics:
+//   - A pattern without a slash matches a file or a directory name at any depth.
+//   - A pattern with a slash at the beginning or in the middle is matched against the whole path.
+//   - A pattern with a trailing slash matches only directories, meaning all files inside them.
+//   - "**" matches any number of directories, "*", "?" and "[...]" work as in path.Match.
+//   - A pattern starting with "!" negates a previous match. The last matching pattern wins.
+type Filter struct {
+	include []rule
+	exclude []rule
+}
+
+type rule struct {
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+func parseRule(original string) (r rule, ok bool, err error) {
+	pattern := strings.TrimRight(original, " \t\r")
+
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule{}, false, nil
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimLeft(pattern, "/")
+
+	if pattern == "" {
+		return rule{}, false, fmt.Errorf("invalid pattern: %q", original)
+	}
+
+	if _, err = path.Match(pattern, ""); err != nil {
+		return rule{}, false, fmt.Errorf("invalid pattern %q: %w", original, err)
+	}
+
+	r.segments = strings.Split(pattern, "/")
+	if !anchored {
+		r.segments = append([]string{"**"}, r.segments...)
+	}
+
+	return r, true, nil
+}
+
+// Include adds a pattern for files that should be processed.
+// If there are no include patterns, all files that are not excluded are processed.
+func (f *Filter) Include(pattern string) error {
+	r, ok, err := parseRule(pattern)
+	if err != nil || !ok {
+		return err
+	}
+
+	f.include = append(f.include, r)
+
+	return nil
+}
+
+// Exclude adds a pattern for files that should not be processed.
+func (f *Filter) Exclude(pattern string) error {
+	r, ok, err := parseRule(pattern)
+	if err != nil || !ok {
+		return err
+	}
+
+	f.exclude = append(f.exclude, r)
+
+	return nil
+}
+
+// ExcludeFrom adds exclude patterns from a file, one per line, in the .gitignore format.
+func (f *Filter) ExcludeFrom(fileName string) (err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to open exclude file %q: %w", fileName, err)
+	}
+
+	defer func() {
+		errClose := file.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("failed to close exclude file %q: %w", fileName, errClose)
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err = f.Exclude(scanner.Text()); err != nil {
+			return fmt.Errorf("exclude file %q: %w", fileName, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read exclude file %q: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// Empty reports whether the filter has no patterns.
+func (f *Filter) Empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// Match reports whether the file should be processed.
+func (f *Filter) Match(fileName string) bool {
+	segments := split(fileName)
+
+	if len(f.include) > 0 && !matchRules(f.include, segments) {
+		return false
+	}
+
+	return !matchRules(f.exclude, segments)
+}
+
+// split converts the file name to a list of path segments relative to the current directory.
+func split(fileName string) []string {
+	if filepath.IsAbs(fileName) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, fileName); err == nil && !strings.HasPrefix(rel, "..") {
+				fileName = rel
+			}
+		}
+	}
+
+	fileName = filepath.ToSlash(filepath.Clean(fileName))
+	fileName = strings.TrimLeft(fileName, "/")
+
+	return strings.Split(fileName, "/")
+}
+
+// matchRules reports whether the last rule that matches the file or one of its parent directories is not negated.
+func matchRules(rules []rule, segments []string) (matched bool) {
+	for _, r := range rules {
+		n := len(segments)
+		if r.dirOnly {
+			n-- // only the parent directories
+		}
+
+		for i := 1; i <= n; i++ {
+			if matchSegments(r.segments, segments[:i]) {
+				matched = !r.negate
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], segments[1:])
+}
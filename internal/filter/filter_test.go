@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		file    string
+		exp     bool
+	}{
+		{
+			name: "no_patterns",
+			file: "data/a.csv",
+			exp:  true,
+		},
+		{
+			name:    "include_match",
+			include: []string{"*.csv"},
+			file:    "data/a.csv",
+			exp:     true,
+		},
+		{
+			name:    "include_no_match",
+			include: []string{"*.csv"},
+			file:    "data/a.txt",
+			exp:     false,
+		},
+		{
+			name:    "exclude_name",
+			exclude: []string{"*.tmp"},
+			file:    "data/x/a.tmp",
+			exp:     false,
+		},
+		{
+			name:    "exclude_dir_name",
+			exclude: []string{"cache/"},
+			file:    "data/cache/a.csv",
+			exp:     false,
+		},
+		{
+			name:    "exclude_dir_only_not_file",
+			exclude: []string{"cache/"},
+			file:    "data/cache",
+			exp:     true,
+		},
+		{
+			name:    "exclude_anchored",
+			exclude: []string{"/cache"},
+			file:    "data/cache/a.csv",
+			exp:     true,
+		},
+		{
+			name:    "exclude_anchored_match",
+			exclude: []string{"data/cache"},
+			file:    "./data/cache/a.csv",
+			exp:     false,
+		},
+		{
+			name:    "exclude_double_star",
+			exclude: []string{"data/**/*.bak"},
+			file:    "data/a/b/c.bak",
+			exp:     false,
+		},
+		{
+			name:    "exclude_double_star_zero_dirs",
+			exclude: []string{"data/**/*.bak"},
+			file:    "data/c.bak",
+			exp:     false,
+		},
+		{
+			name:    "exclude_negated",
+			exclude: []string{"*.log", "!important.log"},
+			file:    "logs/important.log",
+			exp:     true,
+		},
+		{
+			name:    "exclude_last_wins",
+			exclude: []string{"!important.log", "*.log"},
+			file:    "logs/important.log",
+			exp:     false,
+		},
+		{
+			name:    "exclude_comment",
+			exclude: []string{"# *.csv", ""},
+			file:    "a.csv",
+			exp:     true,
+		},
+		{
+			name:    "include_and_exclude",
+			include: []string{"*.csv"},
+			exclude: []string{"tmp/"},
+			file:    "tmp/a.csv",
+			exp:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var f Filter
+
+			for _, pattern := range test.include {
+				if err := f.Include(pattern); err != nil {
+					t.Errorf("failed to add include pattern %q: %v", pattern, err)
+					return
+				}
+			}
+
+			for _, pattern := range test.exclude {
+				if err := f.Exclude(pattern); err != nil {
+					t.Errorf("failed to add exclude pattern %q: %v", pattern, err)
+					return
+				}
+			}
+
+			if got, want := f.Match(test.file), test.exp; got != want {
+				t.Errorf("match mismatch: got=%t want=%t", got, want)
+			}
+		})
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"/", "!//", "[a", "!docs/[a-/"} {
+		var f Filter
+
+		err := f.Include(pattern)
+		if err == nil {
+			t.Errorf("no error for pattern %q", pattern)
+			continue
+		}
+
+		if quoted := strconv.Quote(pattern); !strings.Contains(err.Error(), quoted) {
+			t.Errorf("error %q doesn't show the pattern %s", err.Error(), quoted)
+		}
+	}
+}
@@ -51,3 +51,8 @@ func FromID(id uint) (h HashGen, err error) {
 
 	return
 }
+
+// Weak reports whether the hash function is deprecated because it's no longer collision resistant.
+func (h HashGen) Weak() bool {
+	return crypto.Hash(h.ID) == crypto.MD5 || crypto.Hash(h.ID) == crypto.SHA1
+}
@@ -2,18 +2,24 @@ package header
 
 import (
 	"crypto/aes"
-	"crypto/rand"
+	"crypto/ed25519"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/hashgen"
 	"github.com/marko-gacesa/fenc/internal/values"
 )
 
-const Size = 128
+// Size is the size of the header written by the current version.
+// Headers of older versions can be smaller.
+const Size = 256
+
+// sizeV0 is the size of the header of version 0. All versions start with the same fields.
+const sizeV0 = 128
 
 const (
 	fieldSignatureOffset = 0
@@ -31,23 +37,61 @@ const (
 	fieldIVOffset = fieldHashSumOffset + fieldHashSumSize
 	fieldIVSize   = aes.BlockSize
 
-	reservedOffset = fieldIVOffset + fieldIVSize
+	// fields below are available since version 1
+
+	fieldCompressionOffset = fieldIVOffset + fieldIVSize
+	fieldCompressionSize   = 1
+
+	fieldFlagsOffset = fieldCompressionOffset + fieldCompressionSize
+	fieldFlagsSize   = 4
+
+	fieldSenderSignatureOffset = fieldFlagsOffset + fieldFlagsSize
+	fieldSenderSignatureSize   = ed25519.SignatureSize
+
+	fieldWrappedKeyOffset = fieldSenderSignatureOffset + fieldSenderSignatureSize
+	fieldWrappedKeySize   = WrappedKeySize
+
+	reservedOffset = fieldWrappedKeyOffset + fieldWrappedKeySize
 	reservedSize   = Size - reservedOffset
 )
 
+// WrappedKeySize is the size of the encrypted data key: 12 bytes of nonce,
+// 32 bytes of AES-256 key and 16 bytes of authentication tag.
+const WrappedKeySize = 12 + 32 + 16
+
+// Flags mark optional features used by the file. Readers must refuse files with unknown flags.
+const (
+	flagSigned        uint32 = 1 << iota // the header contains the sender signature
+	flagWrappedKey                       // the data is encrypted with a random key, stored encrypted in the header
+	flagDeterministic                    // the IV is derived from the content, so equal content gives equal output
+	flagNormalized                       // the key phrase was normalized to Unicode NFC before the key was made
+
+	flagsKnown = flagSigned | flagWrappedKey | flagDeterministic | flagNormalized
+)
+
 type Header struct {
-	version uint16
-	hg      hashgen.HashGen
-	hashSum []byte
-	iv      [aes.BlockSize]byte
+	version   uint16
+	hg        hashgen.HashGen
+	hashSum   []byte
+	iv        [aes.BlockSize]byte
+	comp      compress.Method
+	flags     uint32
+	senderSig []byte
+	wrapped   []byte
+	raw       []byte // the header as it was read
 }
 
-func New(hashID uint, iv []byte) *Header {
+func New(hashID uint, compressionID uint8, iv []byte) *Header {
 	hg, err := hashgen.FromID(hashID)
 	if err != nil {
 		panic(err)
 	}
 
+	comp, err := compress.FromID(compressionID)
+	if err != nil {
+		panic(err)
+	}
+
 	if len(iv) != aes.BlockSize {
 		panic("header: invalid iv size")
 	}
@@ -55,6 +99,7 @@ func New(hashID uint, iv []byte) *Header {
 	h := &Header{
 		version: values.Version,
 		hg:      hg,
+		comp:    comp,
 	}
 
 	copy(h.iv[:], iv)
@@ -62,23 +107,37 @@ func New(hashID uint, iv []byte) *Header {
 	return h
 }
 
+// packRaw packs the header to the current version format.
+// Unused bytes are left as zeros, so that future versions can use them for new fields.
 func (h *Header) packRaw(raw *[Size]byte) {
-	_, _ = rand.Read(raw[:])
 	copy(raw[fieldSignatureOffset:fieldSignatureOffset+fieldSignatureSize], values.Signature)
 	binary.LittleEndian.PutUint16(raw[fieldVersionOffset:fieldVersionOffset+fieldVersionSize], h.version)
 	binary.LittleEndian.PutUint16(raw[fieldHashIDOffset:fieldHashIDOffset+fieldHashIDSize], uint16(h.hg.ID))
 	copy(raw[fieldHashSumOffset:fieldHashSumOffset+len(h.hashSum)], h.hashSum)
 	copy(raw[fieldIVOffset:fieldIVOffset+fieldIVSize], h.iv[:])
+	raw[fieldCompressionOffset] = h.comp.ID
+	binary.LittleEndian.PutUint32(raw[fieldFlagsOffset:fieldFlagsOffset+fieldFlagsSize], h.flags)
+	copy(raw[fieldSenderSignatureOffset:fieldSenderSignatureOffset+fieldSenderSignatureSize], h.senderSig)
+	copy(raw[fieldWrappedKeyOffset:fieldWrappedKeyOffset+fieldWrappedKeySize], h.wrapped)
 }
 
-func (h *Header) unpackRaw(raw *[Size]byte) error {
+func unpackVersion(raw []byte) (uint16, error) {
 	if string(raw[fieldSignatureOffset:fieldSignatureOffset+fieldSignatureSize]) != values.Signature {
-		return errors.New("header: signature mismatch")
+		return 0, errors.New("header: signature mismatch")
 	}
 
 	version := binary.LittleEndian.Uint16(raw[fieldVersionOffset : fieldVersionOffset+fieldVersionSize])
 	if version > values.Version {
-		return errors.New("header: unsupported version")
+		return 0, errors.New("header: unsupported version")
+	}
+
+	return version, nil
+}
+
+func (h *Header) unpackRaw(raw []byte) error {
+	version, err := unpackVersion(raw)
+	if err != nil {
+		return err
 	}
 
 	hashID := uint(binary.LittleEndian.Uint16(raw[fieldHashIDOffset : fieldHashIDOffset+fieldHashIDSize]))
@@ -92,9 +151,43 @@ func (h *Header) unpackRaw(raw *[Size]byte) error {
 
 	iv := raw[fieldIVOffset : fieldIVOffset+fieldIVSize]
 
+	compressionID := uint8(compress.IDGzip) // version 0 always uses gzip
+	if version >= 1 {
+		compressionID = raw[fieldCompressionOffset]
+	}
+
+	comp, err := compress.FromID(compressionID)
+	if err != nil {
+		return fmt.Errorf("header: unrecognized compression ID=%d", compressionID)
+	}
+
+	var flags uint32
+	if version >= 1 {
+		flags = binary.LittleEndian.Uint32(raw[fieldFlagsOffset : fieldFlagsOffset+fieldFlagsSize])
+	}
+
+	if flags&^flagsKnown != 0 {
+		return fmt.Errorf("header: unsupported flags=%#x", flags&^flagsKnown)
+	}
+
+	var senderSig []byte
+	if flags&flagSigned != 0 {
+		senderSig = raw[fieldSenderSignatureOffset : fieldSenderSignatureOffset+fieldSenderSignatureSize]
+	}
+
+	var wrapped []byte
+	if flags&flagWrappedKey != 0 {
+		wrapped = raw[fieldWrappedKeyOffset : fieldWrappedKeyOffset+fieldWrappedKeySize]
+	}
+
 	h.version = version
 	h.hashSum = hashSum
 	h.hg = hg
+	h.comp = comp
+	h.flags = flags
+	h.senderSig = senderSig
+	h.wrapped = wrapped
+	h.raw = raw
 	copy(h.iv[:], iv)
 
 	return nil
@@ -116,6 +209,10 @@ func (h *Header) SetHashSum(hashSum []byte) {
 	h.hashSum = hashSum
 }
 
+func (h *Header) GetHashID() uint {
+	return h.hg.ID
+}
+
 func (h *Header) GetVersion() uint16 {
 	return h.version
 }
@@ -128,6 +225,92 @@ func (h *Header) GetIV() []byte {
 	return h.iv[:]
 }
 
+func (h *Header) GetCompression() compress.Method {
+	return h.comp
+}
+
+func (h *Header) IsSigned() bool {
+	return h.flags&flagSigned != 0
+}
+
+func (h *Header) GetSenderSignature() []byte {
+	return h.senderSig
+}
+
+func (h *Header) SetSenderSignature(sig []byte) {
+	if len(sig) != fieldSenderSignatureSize {
+		panic("header: wrong signature size")
+	}
+
+	h.flags |= flagSigned
+	h.senderSig = sig
+}
+
+// ClearSenderSignature removes the sender signature, which is no longer valid after the header is changed.
+func (h *Header) ClearSenderSignature() {
+	h.flags &^= flagSigned
+	h.senderSig = nil
+	h.raw = nil
+}
+
+func (h *Header) HasWrappedKey() bool {
+	return h.flags&flagWrappedKey != 0
+}
+
+func (h *Header) GetWrappedKey() []byte {
+	return h.wrapped
+}
+
+// SetWrappedKey sets the encrypted data key. The header must be written again
+// and the sender signature, if any, is no longer valid.
+func (h *Header) SetWrappedKey(wrapped []byte) {
+	if len(wrapped) != fieldWrappedKeySize {
+		panic("header: wrong wrapped key size")
+	}
+
+	h.flags |= flagWrappedKey
+	h.wrapped = wrapped
+	h.raw = nil
+}
+
+func (h *Header) IsDeterministic() bool {
+	return h.flags&flagDeterministic != 0
+}
+
+// SetDeterministic marks the data as encrypted in the deterministic mode.
+func (h *Header) SetDeterministic() {
+	h.flags |= flagDeterministic
+	h.raw = nil
+}
+
+func (h *Header) IsNormalized() bool {
+	return h.flags&flagNormalized != 0
+}
+
+// SetNormalized marks the data as encrypted with the key made from the normalized key phrase.
+// The header must be written again and the sender signature, if any, is no longer valid.
+func (h *Header) SetNormalized() {
+	h.flags |= flagNormalized
+	h.raw = nil
+}
+
+// SignedContent returns the part of the header covered by the sender signature:
+// the header of a signed file, with the signature field filled with zeros.
+func (h *Header) SignedContent() []byte {
+	var raw [Size]byte
+
+	if h.raw != nil {
+		copy(raw[:], h.raw)
+	} else {
+		h.packRaw(&raw)
+		binary.LittleEndian.PutUint32(raw[fieldFlagsOffset:fieldFlagsOffset+fieldFlagsSize], h.flags|flagSigned)
+	}
+
+	clear(raw[fieldSenderSignatureOffset : fieldSenderSignatureOffset+fieldSenderSignatureSize])
+
+	return raw[:]
+}
+
 func (h *Header) Update(w io.WriteSeeker) error {
 	_, err := w.Seek(0, io.SeekStart)
 	if err != nil {
@@ -155,18 +338,53 @@ func Read(r io.Reader) (*Header, error) {
 
 	var raw [Size]byte
 
-	n, err := r.Read(raw[:])
+	n, err := io.ReadFull(r, raw[:sizeV0])
 	if err != nil {
-		return nil, fmt.Errorf("header: failed to read: %w", err)
+		return nil, fmt.Errorf("header: read %d of %d bytes: %w", n, sizeV0, err)
 	}
-	if n != Size {
-		return nil, fmt.Errorf("header: read %d of %d bytes", n, Size)
+
+	version, err := unpackVersion(raw[:])
+	if err != nil {
+		return nil, err
+	}
+
+	size := sizeV0
+	if version >= 1 {
+		size = Size
+	}
+
+	n, err = io.ReadFull(r, raw[sizeV0:size])
+	if err != nil {
+		return nil, fmt.Errorf("header: read %d of %d bytes: %w", sizeV0+n, size, err)
 	}
 
-	err = h.unpackRaw(&raw)
+	err = h.unpackRaw(raw[:size])
 	if err != nil {
 		return nil, err
 	}
 
 	return h, nil
 }
+
+// Probe reads the beginning of a stream and reports whether it starts with the fenc signature.
+// If it does, the format version stored right after the signature is returned as well.
+// Streams shorter than the signature and the version are reported as not being fenc data.
+func Probe(r io.Reader) (version uint16, ok bool, err error) {
+	var raw [fieldVersionOffset + fieldVersionSize]byte
+
+	_, err = io.ReadFull(r, raw[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("header: failed to read: %w", err)
+	}
+
+	if string(raw[fieldSignatureOffset:fieldSignatureOffset+fieldSignatureSize]) != values.Signature {
+		return 0, false, nil
+	}
+
+	version = binary.LittleEndian.Uint16(raw[fieldVersionOffset : fieldVersionOffset+fieldVersionSize])
+
+	return version, true, nil
+}
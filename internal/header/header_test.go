@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"testing"
 
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/values"
 )
 
@@ -18,7 +19,7 @@ func TestHeader(t *testing.T) {
 	}
 
 	raw, hashSum := func() (raw, hashSum []byte) {
-		h := New(uint(crypto.MD5), iv[:])
+		h := New(uint(crypto.MD5), compress.IDGzip, iv[:])
 
 		hasher := h.Hash()
 		_, _ = hasher.Write([]byte("12345678"))
@@ -51,3 +52,55 @@ func TestHeader(t *testing.T) {
 		t.Errorf("iv mismatch: got=%x want=%x", got, want)
 	}
 }
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		expOK      bool
+		expVersion uint16
+	}{
+		{
+			name: "empty",
+			data: nil,
+		},
+		{
+			name: "short",
+			data: []byte(values.Signature),
+		},
+		{
+			name: "plain_text",
+			data: []byte("Lorem ipsum dolor sit amet"),
+		},
+		{
+			name:       "signature",
+			data:       []byte(values.Signature + "\x00\x00"),
+			expOK:      true,
+			expVersion: 0,
+		},
+		{
+			name:       "signature_future_version",
+			data:       []byte(values.Signature + "\x07\x00"),
+			expOK:      true,
+			expVersion: 7,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, ok, err := Probe(bytes.NewReader(test.data))
+			if err != nil {
+				t.Errorf("failed with error: %v", err)
+				return
+			}
+
+			if got, want := ok, test.expOK; got != want {
+				t.Errorf("ok mismatch: got=%t want=%t", got, want)
+			}
+
+			if got, want := version, test.expVersion; got != want {
+				t.Errorf("version mismatch: got=%d want=%d", got, want)
+			}
+		})
+	}
+}
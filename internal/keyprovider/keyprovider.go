@@ -0,0 +1,336 @@
+package keyprovider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/marko-gacesa/fenc/internal/agent"
+	"github.com/marko-gacesa/fenc/internal/masterkey"
+	"github.com/marko-gacesa/fenc/internal/password"
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+// Request describes the key phrase that is needed.
+type Request struct {
+	Prompt   string // the prompt for the user, like "Enter key phrase"
+	Confirm  bool   // the key phrase is new, so it should be entered twice
+	Strength bool   // the key phrase must be strong enough
+	MinScore int    // the minimum strength score, see password.Estimate; used only if Strength is set
+}
+
+// Provider provides the key phrase.
+type Provider interface {
+	KeyPhrase(req Request) ([]byte, error)
+}
+
+// ExternalPrefix is the prefix of the name of executables that implement external providers.
+// The provider "NAME:ARG" runs the executable "fenc-key-NAME" with ARG as its argument.
+const ExternalPrefix = "fenc-key-"
+
+var (
+	// ErrorUnavailable means that the provider has no key phrase, so the next provider in a Chain is tried.
+	ErrorUnavailable    = errors.New("key phrase not available")
+	ErrorUnsupportedURI = errors.New("unsupported key provider")
+)
+
+// FromURI returns the provider selected by the URI, which is one of:
+//
+//	prompt           asks the user
+//	blank            the empty key phrase
+//	env:VAR          the value of the environment variable
+//	file:PATH        the content of the file, without the final line break
+//	fd:N             the content read from the file descriptor, without the final line break
+//	cmd:COMMAND      the output of the shell command, without the final line break
+//	agent[:NAME]     the key held by the agent, see the agent package
+//	master:PATH      the master key from the file, see the masterkey package
+//	shares:PATH,...  the master key rebuilt from the files with recovery shares
+//	NAME:ARG         the output of the external provider, the executable fenc-key-NAME
+func FromURI(uri string) (Provider, error) {
+	scheme, arg, hasArg := strings.Cut(uri, ":")
+
+	switch scheme {
+	case "prompt", "blank":
+		if hasArg {
+			return nil, fmt.Errorf("%w: %q doesn't take an argument", ErrorUnsupportedURI, scheme)
+		}
+
+		if scheme == "prompt" {
+			return Prompt{}, nil
+		}
+
+		return Blank{}, nil
+
+	case "agent":
+		if !hasArg {
+			arg = agent.DefaultKeyName
+		}
+
+		return Agent{Name: arg}, nil
+	}
+
+	if !hasArg || arg == "" {
+		return nil, fmt.Errorf("%w: %q", ErrorUnsupportedURI, uri)
+	}
+
+	switch scheme {
+	case "env":
+		return Env{Name: arg}, nil
+
+	case "file":
+		return File{Path: arg}, nil
+
+	case "fd":
+		fd, err := strconv.ParseUint(arg, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid file descriptor %q", ErrorUnsupportedURI, arg)
+		}
+
+		return FD{FD: uintptr(fd)}, nil
+
+	case "cmd":
+		return Command{Line: arg}, nil
+
+	case "master":
+		return MasterKey{Path: arg}, nil
+
+	case "shares":
+		return Shares{Paths: strings.Split(arg, ",")}, nil
+	}
+
+	if !validExternalName(scheme) {
+		return nil, fmt.Errorf("%w: %q", ErrorUnsupportedURI, uri)
+	}
+
+	path, err := exec.LookPath(ExternalPrefix + scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrorUnsupportedURI, uri, err)
+	}
+
+	return External{Path: path, Arg: arg}, nil
+}
+
+// Chain tries the providers in order, until one of them doesn't fail with ErrorUnavailable.
+type Chain []Provider
+
+func (c Chain) KeyPhrase(req Request) ([]byte, error) {
+	err := ErrorUnavailable
+
+	for _, p := range c {
+		var key []byte
+
+		key, err = p.KeyPhrase(req)
+		if !errors.Is(err, ErrorUnavailable) {
+			return key, err
+		}
+	}
+
+	return nil, err
+}
+
+// Prompt asks the user for the key phrase.
+type Prompt struct{}
+
+func (Prompt) KeyPhrase(req Request) ([]byte, error) {
+	minScore := password.NoStrengthCheck
+	if req.Strength {
+		minScore = req.MinScore
+	}
+
+	return password.InputWithPrompt(req.Prompt, minScore, req.Confirm)
+}
+
+// Blank provides the empty key phrase. It's insecure, so its strength is never checked.
+type Blank struct{}
+
+func (Blank) KeyPhrase(Request) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// Literal provides the key phrase given in the command line.
+type Literal []byte
+
+func (l Literal) KeyPhrase(req Request) ([]byte, error) {
+	return checked(req, secure.LockedCopy(l))
+}
+
+// Env reads the key phrase from the environment variable.
+type Env struct {
+	Name string
+}
+
+func (e Env) KeyPhrase(req Request) ([]byte, error) {
+	value, ok := os.LookupEnv(e.Name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("%w: environment variable %s is not defined or has no value", ErrorUnavailable, e.Name)
+	}
+
+	return checked(req, secure.LockedString(value))
+}
+
+// File reads the key phrase from the file, like a secret mounted by a container runtime.
+type File struct {
+	Path string
+}
+
+func (f File) KeyPhrase(req Request) ([]byte, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key phrase file: %w", err)
+	}
+
+	return checked(req, lockedKey(data))
+}
+
+// FD reads the key phrase from the file descriptor, inherited from the parent process.
+type FD struct {
+	FD uintptr
+}
+
+func (f FD) KeyPhrase(req Request) ([]byte, error) {
+	file := os.NewFile(f.FD, "fd:"+strconv.FormatUint(uint64(f.FD), 10))
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", f.FD)
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key phrase from file descriptor %d: %w", f.FD, err)
+	}
+
+	return checked(req, lockedKey(data))
+}
+
+// Command runs the shell command and uses its output as the key phrase.
+// The command shares stdin and stderr with fenc, so it can interact with the user.
+type Command struct {
+	Line string
+}
+
+func (c Command) KeyPhrase(req Request) ([]byte, error) {
+	cmd := exec.Command("/bin/sh", "-c", c.Line)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("key phrase command failed: %w", err)
+	}
+
+	return checked(req, lockedKey(data))
+}
+
+// External runs the external provider. The request is written to its stdin, one "name=value" per line:
+//
+//	version=1
+//	prompt=Enter key phrase
+//	confirm=false
+//
+// The provider writes the key phrase to stdout, optionally followed by a line break, and exits with 0.
+// The stderr is shared with fenc, so it can interact with the user through the terminal.
+type External struct {
+	Path string
+	Arg  string
+}
+
+func (e External) KeyPhrase(req Request) ([]byte, error) {
+	cmd := exec.Command(e.Path, e.Arg)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("version=1\nprompt=%s\nconfirm=%t\n",
+		strings.ReplaceAll(req.Prompt, "\n", " "), req.Confirm))
+	cmd.Stderr = os.Stderr
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("key provider %s failed: %w", e.Path, err)
+	}
+
+	return checked(req, lockedKey(data))
+}
+
+// Agent gets the key from the agent that listens on the socket from the environment variable agent.EnvSocket.
+// The agent holds keys, not key phrases, but a key works as the key phrase that gives the same key.
+// The strength is checked when the key is added to the agent.
+type Agent struct {
+	Name string
+}
+
+func (a Agent) KeyPhrase(Request) ([]byte, error) {
+	socket := os.Getenv(agent.EnvSocket)
+	if socket == "" {
+		return nil, fmt.Errorf("%w: %s is not set", ErrorUnavailable, agent.EnvSocket)
+	}
+
+	key, err := agent.Get(socket, a.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorUnavailable, err)
+	}
+
+	return key, nil
+}
+
+// MasterKey reads the master key from the file. Like the key from the agent, it works as the key phrase.
+type MasterKey struct {
+	Path string
+}
+
+func (m MasterKey) KeyPhrase(Request) ([]byte, error) {
+	return masterkey.ReadFile(m.Path)
+}
+
+// Shares rebuilds the master key from the files with recovery shares. The master key is never stored.
+type Shares struct {
+	Paths []string
+}
+
+func (s Shares) KeyPhrase(Request) ([]byte, error) {
+	shares, err := masterkey.ReadShareFiles(s.Paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recovery shares: %w", err)
+	}
+
+	defer masterkey.WipeShares(shares)
+
+	return masterkey.Combine(shares)
+}
+
+func checked(req Request, key []byte) ([]byte, error) {
+	if req.Strength {
+		if err := password.CheckStrength(key, req.MinScore); err != nil {
+			secure.Wipe(key)
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// lockedKey returns the key phrase from the data, without the final line break, in locked memory.
+// The data is wiped.
+func lockedKey(data []byte) []byte {
+	key := secure.LockedCopy(trimLineBreak(data))
+	clear(data)
+
+	return key
+}
+
+func trimLineBreak(data []byte) []byte {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return bytes.TrimSuffix(data, []byte("\r"))
+}
+
+func validExternalName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+
+	return name != ""
+}
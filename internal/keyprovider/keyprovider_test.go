@@ -0,0 +1,164 @@
+package keyprovider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marko-gacesa/fenc/internal/password"
+)
+
+func TestFromURI(t *testing.T) {
+	tests := []struct {
+		uri    string
+		exp    Provider
+		expErr error
+	}{
+		{uri: "prompt", exp: Prompt{}},
+		{uri: "blank", exp: Blank{}},
+		{uri: "env:KEY", exp: Env{Name: "KEY"}},
+		{uri: "file:/run/secrets/key", exp: File{Path: "/run/secrets/key"}},
+		{uri: "fd:3", exp: FD{FD: 3}},
+		{uri: "cmd:pass show fenc", exp: Command{Line: "pass show fenc"}},
+		{uri: "agent", exp: Agent{Name: "default"}},
+		{uri: "agent:backup", exp: Agent{Name: "backup"}},
+		{uri: "master:dr.key", exp: MasterKey{Path: "dr.key"}},
+		{uri: "shares:a.share,b.share", exp: Shares{Paths: []string{"a.share", "b.share"}}},
+		{uri: "prompt:x", expErr: ErrorUnsupportedURI},
+		{uri: "env:", expErr: ErrorUnsupportedURI},
+		{uri: "fd:x", expErr: ErrorUnsupportedURI},
+		{uri: "Vault:x", expErr: ErrorUnsupportedURI},
+		{uri: "missing-provider:x", expErr: ErrorUnsupportedURI},
+		{uri: "key", expErr: ErrorUnsupportedURI},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uri, func(t *testing.T) {
+			p, err := FromURI(test.uri)
+			if !errors.Is(err, test.expErr) {
+				t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+				return
+			}
+
+			if !reflect.DeepEqual(p, test.exp) {
+				t.Errorf("provider mismatch: got=%#v want=%#v", p, test.exp)
+			}
+		})
+	}
+}
+
+func TestKeyPhrase(t *testing.T) {
+	dir := t.TempDir()
+
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("vapor-unlatch-garnet-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	external := filepath.Join(dir, ExternalPrefix+"test")
+	script := "#!/bin/sh\ngrep -q '^confirm=false$' && echo \"ext-$1-2\"\n"
+	if err := os.WriteFile(external, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TEST_KEY_PHRASE", "rigid-mosaic-pelican-3")
+	t.Setenv("TEST_WEAK_KEY_PHRASE", "weak")
+
+	tests := []struct {
+		uri      string
+		strength bool
+		exp      string
+		expErr   error
+	}{
+		{uri: "env:TEST_KEY_PHRASE", strength: true, exp: "rigid-mosaic-pelican-3"},
+		{uri: "env:TEST_WEAK_KEY_PHRASE", exp: "weak"},
+		{uri: "env:TEST_WEAK_KEY_PHRASE", strength: true, expErr: errAny},
+		{uri: "env:TEST_UNDEFINED_KEY_PHRASE", expErr: ErrorUnavailable},
+		{uri: "file:" + keyFile, strength: true, exp: "vapor-unlatch-garnet-1"},
+		{uri: "file:" + filepath.Join(dir, "none"), expErr: errAny},
+		{uri: "cmd:printf 'cmd-secret-4\\r\\n'", exp: "cmd-secret-4"},
+		{uri: "cmd:exit 1", expErr: errAny},
+		{uri: "test:x", exp: "ext-x-2"},
+		{uri: "blank", strength: true, exp: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uri, func(t *testing.T) {
+			p, err := FromURI(test.uri)
+			if err != nil {
+				t.Errorf("failed to parse uri: %v", err)
+				return
+			}
+
+			key, err := p.KeyPhrase(Request{Prompt: "Enter key phrase", Strength: test.strength, MinScore: password.DefaultMinScore})
+			if test.expErr == errAny && err != nil {
+				return
+			}
+
+			if !errors.Is(err, test.expErr) {
+				t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+				return
+			}
+
+			if string(key) != test.exp {
+				t.Errorf("key mismatch: got=%q want=%q", key, test.exp)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Setenv("TEST_KEY_PHRASE", "rigid-mosaic-pelican-3")
+
+	tests := []struct {
+		name   string
+		chain  Chain
+		exp    string
+		expErr error
+	}{
+		{
+			name:  "first",
+			chain: Chain{Env{Name: "TEST_KEY_PHRASE"}, Literal("literal")},
+			exp:   "rigid-mosaic-pelican-3",
+		},
+		{
+			name:  "fallback",
+			chain: Chain{Env{Name: "TEST_UNDEFINED_KEY_PHRASE"}, Literal("literal")},
+			exp:   "literal",
+		},
+		{
+			name:   "none",
+			chain:  Chain{Env{Name: "TEST_UNDEFINED_KEY_PHRASE"}},
+			expErr: ErrorUnavailable,
+		},
+		{
+			name:   "error",
+			chain:  Chain{Command{Line: "exit 1"}, Literal("literal")},
+			expErr: errAny,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := test.chain.KeyPhrase(Request{})
+			if test.expErr == errAny && err != nil {
+				return
+			}
+
+			if !errors.Is(err, test.expErr) {
+				t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+				return
+			}
+
+			if string(key) != test.exp {
+				t.Errorf("key mismatch: got=%q want=%q", key, test.exp)
+			}
+		})
+	}
+}
+
+// errAny is expected by tests that only check that there is an error.
+var errAny = errors.New("any error")
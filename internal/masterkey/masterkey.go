@@ -0,0 +1,321 @@
+package masterkey
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/marko-gacesa/fenc/internal/secure"
+	"github.com/marko-gacesa/fenc/internal/shamir"
+)
+
+// A master key is a random AES-256 key, used instead of a key phrase. Files encrypted with it
+// have their data keys wrapped with the master key. It can be split into recovery shares,
+// any threshold of which rebuild it, so that no single holder of a share can decrypt the files.
+//
+// Master keys and shares are stored as text, one per line, so they can be printed:
+//
+//	fenc-master-key:<key in hex>
+//	fenc-share:1:<threshold>:<index>:<key id>:<share in hex>
+//
+// The key id is the beginning of the SHA-256 of the master key. It tells which shares belong together
+// and verifies the rebuilt key. Lines starting with # are comments.
+
+// Size is the size of the master key.
+const Size = 32
+
+const (
+	keyPrefix    = "fenc-master-key:"
+	sharePrefix  = "fenc-share:"
+	shareVersion = 1
+	keyIDSize    = 4
+)
+
+var (
+	ErrorInvalidKey     = errors.New("invalid master key")
+	ErrorInvalidShare   = errors.New("invalid recovery share")
+	ErrorMixedShares    = errors.New("recovery shares belong to different master keys")
+	ErrorTooFewShares   = errors.New("not enough recovery shares")
+	ErrorCorruptedShare = errors.New("recovery shares don't rebuild the master key (corrupted share?)")
+)
+
+// Generate returns a new random master key, in locked memory.
+func Generate() ([]byte, error) {
+	key := secure.Alloc(Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Format returns the master key as a line of text, in locked memory.
+func Format(key []byte) []byte {
+	text := secure.Alloc(len(keyPrefix) + hex.EncodedLen(len(key)) + 1)
+	n := copy(text, keyPrefix)
+	hex.Encode(text[n:], key)
+	text[len(text)-1] = '\n'
+
+	return text
+}
+
+// Parse reads the master key from the text produced by Format.
+func Parse(data []byte) ([]byte, error) {
+	lines, err := readLines(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer wipeLines(lines)
+
+	if len(lines) != 1 || !bytes.HasPrefix(lines[0], []byte(keyPrefix)) {
+		return nil, ErrorInvalidKey
+	}
+
+	key, err := decodeHex(bytes.TrimPrefix(lines[0], []byte(keyPrefix)))
+	if err != nil || len(key) != Size {
+		secure.Wipe(key)
+		return nil, ErrorInvalidKey
+	}
+
+	return key, nil
+}
+
+// ReadFile reads the master key from the file.
+func ReadFile(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer clear(data)
+
+	key, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, fileName)
+	}
+
+	return key, nil
+}
+
+// WriteFile writes the master key to a new file, readable only by the owner.
+func WriteFile(fileName string, key []byte) (err error) {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = errClose
+		}
+	}()
+
+	text := Format(key)
+	defer secure.Wipe(text)
+
+	_, err = f.Write(text)
+
+	return
+}
+
+// Split splits the master key into n shares, any threshold of which rebuild it.
+// Each share is a line of text, in locked memory.
+func Split(key []byte, n, threshold int) ([][]byte, error) {
+	if len(key) != Size {
+		return nil, ErrorInvalidKey
+	}
+
+	shares, err := shamir.Split(key, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	id := keyID(key)
+
+	lines := make([][]byte, len(shares))
+	for i, s := range shares {
+		prefix := fmt.Sprintf("%s%d:%d:%d:%s:", sharePrefix, shareVersion, threshold, s.X, id)
+
+		lines[i] = secure.Alloc(len(prefix) + hex.EncodedLen(len(s.Y)))
+		hex.Encode(lines[i][copy(lines[i], prefix):], s.Y)
+
+		clear(s.Y)
+	}
+
+	return lines, nil
+}
+
+// Combine rebuilds the master key from the shares, produced by Split. The master key is in locked memory.
+func Combine(lines [][]byte) ([]byte, error) {
+	var (
+		shares    []shamir.Share
+		threshold int
+		id        string
+	)
+
+	defer func() {
+		for _, s := range shares {
+			secure.Wipe(s.Y)
+		}
+	}()
+
+	for _, line := range lines {
+		s, shareThreshold, shareID, err := parseShare(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(shares) > 0 && (shareThreshold != threshold || shareID != id) {
+			secure.Wipe(s.Y)
+			return nil, ErrorMixedShares
+		}
+
+		threshold = shareThreshold
+		id = shareID
+		shares = append(shares, s)
+	}
+
+	if len(shares) < threshold || len(shares) == 0 {
+		return nil, fmt.Errorf("%w: got %d, need %d", ErrorTooFewShares, len(shares), threshold)
+	}
+
+	combined, err := shamir.Combine(shares)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidShare, err)
+	}
+
+	defer clear(combined)
+
+	if keyID(combined) != id {
+		return nil, ErrorCorruptedShare
+	}
+
+	return secure.LockedCopy(combined), nil
+}
+
+// ReadShares reads shares from the reader, one per line, into locked memory. Empty lines and comments are skipped.
+func ReadShares(r io.Reader) ([][]byte, error) {
+	return readLines(r)
+}
+
+// ReadShareFiles reads shares from the files.
+func ReadShareFiles(fileNames []string) ([][]byte, error) {
+	var lines [][]byte
+
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			wipeLines(lines)
+			return nil, err
+		}
+
+		fileLines, err := ReadShares(bytes.NewReader(data))
+		clear(data)
+		if err != nil {
+			wipeLines(lines)
+			return nil, fmt.Errorf("failed to read %q: %w", fileName, err)
+		}
+
+		lines = append(lines, fileLines...)
+	}
+
+	return lines, nil
+}
+
+// WipeShares wipes the shares returned by Split, ReadShares or ReadShareFiles.
+func WipeShares(lines [][]byte) {
+	wipeLines(lines)
+}
+
+// ShareComment returns the comment line that describes the share, for share files and printouts.
+func ShareComment(index, n, threshold int) string {
+	return fmt.Sprintf("# fenc recovery share %d of %d, any %d of them rebuild the master key", index, n, threshold)
+}
+
+func parseShare(line []byte) (s shamir.Share, threshold int, id string, err error) {
+	fields := bytes.Split(bytes.TrimPrefix(line, []byte(sharePrefix)), []byte(":"))
+	if !bytes.HasPrefix(line, []byte(sharePrefix)) || len(fields) != 5 {
+		err = ErrorInvalidShare
+		return
+	}
+
+	if string(fields[0]) != strconv.Itoa(shareVersion) {
+		err = fmt.Errorf("%w: unsupported version %s", ErrorInvalidShare, fields[0])
+		return
+	}
+
+	threshold, errThreshold := strconv.Atoi(string(fields[1]))
+	x, errX := strconv.ParseUint(string(fields[2]), 10, 8)
+	y, errY := decodeHex(fields[4])
+	if errThreshold != nil || errX != nil || errY != nil || x == 0 || threshold < 2 || len(y) != Size {
+		secure.Wipe(y)
+		err = ErrorInvalidShare
+		return
+	}
+
+	s = shamir.Share{X: byte(x), Y: y}
+	id = string(fields[3])
+
+	return
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+// decodeHex decodes the hex text into locked memory.
+func decodeHex(text []byte) ([]byte, error) {
+	data := secure.Alloc(hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(data, text); err != nil {
+		secure.Wipe(data)
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// maxLineSize limits the length of lines, so that the buffer of the scanner, which is wiped, is never replaced.
+const maxLineSize = 4096
+
+// readLines reads the lines with content into locked memory.
+func readLines(r io.Reader) ([][]byte, error) {
+	var lines [][]byte
+
+	buf := make([]byte, maxLineSize)
+	defer clear(buf)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(buf, len(buf))
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		lines = append(lines, secure.LockedCopy(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		wipeLines(lines)
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func wipeLines(lines [][]byte) {
+	for _, line := range lines {
+		secure.Wipe(line)
+	}
+}
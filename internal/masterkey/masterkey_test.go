@@ -0,0 +1,81 @@
+package masterkey
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	key, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := Split(key, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, _ := Generate()
+	otherShares, _ := Split(otherKey, 5, 3)
+
+	// a different, but valid, hex digit
+	corrupted := bytes.Clone(shares[1])
+	if corrupted[len(corrupted)-1] == '0' {
+		corrupted[len(corrupted)-1] = '1'
+	} else {
+		corrupted[len(corrupted)-1] = '0'
+	}
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+		expErr error
+	}{
+		{name: "three", shares: [][]byte{shares[4], shares[0], shares[2]}},
+		{name: "all", shares: shares},
+		{name: "two", shares: shares[:2], expErr: ErrorTooFewShares},
+		{name: "none", shares: nil, expErr: ErrorTooFewShares},
+		{name: "mixed", shares: [][]byte{shares[0], shares[1], otherShares[2]}, expErr: ErrorMixedShares},
+		{name: "corrupted", shares: [][]byte{shares[0], corrupted, shares[2]}, expErr: ErrorCorruptedShare},
+		{name: "invalid", shares: [][]byte{shares[0], shares[1], []byte("fenc-share:1:3:3:00:00")}, expErr: ErrorInvalidShare},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Combine(test.shares)
+			if !errors.Is(err, test.expErr) {
+				t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+				return
+			}
+
+			if err == nil && !bytes.Equal(got, key) {
+				t.Errorf("key mismatch: got=%x want=%x", got, key)
+			}
+		})
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	key, _ := Generate()
+
+	text := append([]byte("# master key\n"), Format(key)...)
+
+	got, err := Parse(text)
+	if err != nil {
+		t.Errorf("failed to parse: %v", err)
+		return
+	}
+
+	if !bytes.Equal(got, key) {
+		t.Errorf("key mismatch: got=%x want=%x", got, key)
+	}
+
+	truncated := bytes.TrimSpace(Format(key))
+	truncated = truncated[:len(truncated)-2]
+
+	if _, err = Parse(truncated); !errors.Is(err, ErrorInvalidKey) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorInvalidKey)
+	}
+}
@@ -0,0 +1,109 @@
+package padding
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Method describes how the size of encrypted data is rounded up to hide its exact length.
+type Method struct {
+	Name  string
+	Block int64
+}
+
+var ErrorUnsupportedPadding = errors.New("unsupported padding")
+
+// minBucket is the smallest size produced by the bucket padding.
+const minBucket = 1 << 10
+
+// FromName returns the padding method for the name. Supported names are
+// none, padme, bucket and block:N, where N is the block size in bytes,
+// optionally with a K, M or G suffix.
+func FromName(name string) (m Method, err error) {
+	method, size, hasSize := strings.Cut(name, ":")
+
+	switch method {
+	case "none", "padme", "bucket":
+		if hasSize {
+			return Method{}, ErrorUnsupportedPadding
+		}
+
+		return Method{Name: method}, nil
+	case "block":
+		if !hasSize {
+			return Method{}, ErrorUnsupportedPadding
+		}
+
+		block, err := parseSize(size)
+		if err != nil || block <= 0 {
+			return Method{}, ErrorUnsupportedPadding
+		}
+
+		return Method{Name: method, Block: block}, nil
+	}
+
+	return Method{}, ErrorUnsupportedPadding
+}
+
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > math.MaxInt64/multiplier || n < math.MinInt64/multiplier {
+		return 0, strconv.ErrRange
+	}
+
+	return n * multiplier, nil
+}
+
+// Size returns the padded size for data of size n. It's never smaller than n.
+func (m Method) Size(n int64) int64 {
+	switch m.Name {
+	case "padme":
+		return padme(n)
+	case "bucket":
+		if n <= minBucket {
+			return minBucket
+		}
+		return 1 << bits.Len64(uint64(n-1))
+	case "block":
+		return (n + m.Block - 1) / m.Block * m.Block
+	}
+
+	return n
+}
+
+// padme implements the Padmé padding: the size is rounded up so that only
+// the top log2(log2(n))+1 bits can be non-zero. It wastes at most 12% of space,
+// while leaking only O(log log n) bits of information about the size.
+func padme(n int64) int64 {
+	if n < 2 {
+		return n
+	}
+
+	e := bits.Len64(uint64(n)) - 1 // floor(log2(n))
+	s := bits.Len64(uint64(e))     // floor(log2(e)) + 1
+	mask := int64(1)<<(e-s) - 1
+
+	return (n + mask) &^ mask
+}
@@ -0,0 +1,49 @@
+package padding
+
+import (
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		exp  int64
+	}{
+		{name: "none", size: 1234, exp: 1234},
+		{name: "block:16", size: 0, exp: 0},
+		{name: "block:16", size: 1, exp: 16},
+		{name: "block:16", size: 32, exp: 32},
+		{name: "block:1K", size: 1025, exp: 2048},
+		{name: "bucket", size: 10, exp: 1024},
+		{name: "bucket", size: 1025, exp: 2048},
+		{name: "bucket", size: 4096, exp: 4096},
+		{name: "padme", size: 1, exp: 1},
+		{name: "padme", size: 9, exp: 10},
+		{name: "padme", size: 1000, exp: 1024},
+		{name: "padme", size: 1000000, exp: 1015808},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := FromName(test.name)
+			if err != nil {
+				t.Errorf("failed with error: %v", err)
+				return
+			}
+
+			if got, want := m.Size(test.size), test.exp; got != want {
+				t.Errorf("size mismatch for %d: got=%d want=%d", test.size, got, want)
+			}
+		})
+	}
+}
+
+func TestFromName(t *testing.T) {
+	for _, name := range []string{"block", "block:0", "block:-5", "block:x", "padme:2", "bucket:1K", "random",
+		"block:18014398509481985K", "block:8589934593G"} {
+		if _, err := FromName(name); err != ErrorUnsupportedPadding {
+			t.Errorf("expected error for %q, got=%v", name, err)
+		}
+	}
+}
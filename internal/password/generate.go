@@ -0,0 +1,162 @@
+package password
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+// wordList is the EFF large wordlist for passphrases, 7776 words (CC BY 3.0 US, Electronic Frontier Foundation):
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed wordlist.txt
+var wordList string
+
+var words = sync.OnceValue(func() []string {
+	return strings.Fields(wordList)
+})
+
+// generatorChars are the characters of generated passwords: printable ASCII, except space.
+const generatorChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// MaxKeyLen is the number of bytes of a key phrase that make the key, see processor.Key.
+// The rest of a longer key phrase doesn't add any strength.
+const MaxKeyLen = 32
+
+var ErrorInvalidLength = errors.New("invalid length of the generated key phrase, it must fit in the bytes that make the key")
+
+// GenerateWords returns a passphrase of n random words from the wordlist, separated with sep,
+// and its entropy in bits. Only the first MaxKeyLen bytes of a key phrase make the key, so the passphrase
+// is chosen uniformly from the ones that aren't longer, and the entropy is the one of that choice.
+// It fails if n words never fit. The passphrase is in locked memory, see secure.Alloc.
+func GenerateWords(n int, sep string) ([]byte, float64, error) {
+	budget := MaxKeyLen - len(sep)*(n-1) // for the words, without separators
+	if n <= 0 || budget <= 0 {
+		return nil, 0, ErrorInvalidLength
+	}
+
+	byLen := wordsByLen()
+
+	// fits[k][b] is the number of choices of k words whose lengths add up to at most b
+	fits := make([][]*big.Int, n+1)
+	for k := range fits {
+		fits[k] = make([]*big.Int, budget+1)
+		for b := range fits[k] {
+			fits[k][b] = new(big.Int)
+			if k == 0 {
+				fits[k][b].SetInt64(1)
+				continue
+			}
+
+			for l := 1; l < len(byLen) && l <= b; l++ {
+				count := big.NewInt(int64(len(byLen[l])))
+				fits[k][b].Add(fits[k][b], count.Mul(count, fits[k-1][b-l]))
+			}
+		}
+	}
+
+	total := fits[n][budget]
+	if total.Sign() == 0 {
+		return nil, 0, ErrorInvalidLength
+	}
+
+	// the chosen words tell the passphrase, so they are cleared too
+	chosen := make([]string, n)
+	defer clear(chosen)
+
+	size := len(sep) * (n - 1)
+	for i := range chosen {
+		r, err := rand.Int(rand.Reader, fits[n-i][budget])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to generate random number: %w", err)
+		}
+
+		// the length of the word is chosen first, weighted by the number of choices it leaves
+		for l := 1; l < len(byLen) && l <= budget; l++ {
+			rest := fits[n-i-1][budget-l]
+
+			weight := big.NewInt(int64(len(byLen[l])))
+			weight.Mul(weight, rest)
+
+			if r.Cmp(weight) >= 0 {
+				r.Sub(r, weight)
+				continue
+			}
+
+			chosen[i] = byLen[l][r.Div(r, rest).Int64()]
+			break
+		}
+
+		clear(r.Bits())
+
+		budget -= len(chosen[i])
+		size += len(chosen[i])
+	}
+
+	phrase := secure.Alloc(size)
+	pos := 0
+
+	for i, w := range chosen {
+		if i > 0 {
+			pos += copy(phrase[pos:], sep)
+		}
+
+		pos += copy(phrase[pos:], w)
+	}
+
+	entropy, _ := new(big.Float).SetInt(total).Float64()
+
+	return phrase, math.Log2(entropy), nil
+}
+
+// wordsByLen returns the words of the wordlist grouped by length, at the index of their length.
+var wordsByLen = sync.OnceValue(func() [][]string {
+	var byLen [][]string
+	for _, w := range words() {
+		for len(byLen) <= len(w) {
+			byLen = append(byLen, nil)
+		}
+
+		byLen[len(w)] = append(byLen[len(w)], w)
+	}
+
+	return byLen
+})
+
+// GenerateChars returns a password of n random printable ASCII characters and its entropy in bits.
+// It can't be longer than MaxKeyLen. The password is in locked memory, see secure.Alloc.
+func GenerateChars(n int) ([]byte, float64, error) {
+	if n <= 0 || n > MaxKeyLen {
+		return nil, 0, ErrorInvalidLength
+	}
+
+	phrase := secure.Alloc(n)
+
+	for i := range phrase {
+		idx, err := randomIndex(len(generatorChars))
+		if err != nil {
+			secure.Wipe(phrase)
+			return nil, 0, err
+		}
+
+		phrase[i] = generatorChars[idx]
+	}
+
+	return phrase, float64(n) * math.Log2(float64(len(generatorChars))), nil
+}
+
+func randomIndex(n int) (int, error) {
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate random number: %w", err)
+	}
+
+	return int(idx.Int64()), nil
+}
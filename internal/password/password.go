@@ -4,75 +4,87 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"unicode"
-	"unicode/utf8"
+	"io"
+	"os"
 
+	"github.com/marko-gacesa/fenc/internal/secure"
 	"golang.org/x/term"
 )
 
+var ErrorNoTerminal = errors.New("no terminal to ask for the key phrase")
+
+// NoStrengthCheck is the minimum score that disables the strength check, for key phrases that already exist.
+const NoStrengthCheck = -1
+
 func Input(strength, retype bool) ([]byte, error) {
-	key, err := input("Enter key phrase: ")
-	if err != nil {
-		return nil, err
+	minScore := NoStrengthCheck
+	if strength {
+		minScore = DefaultMinScore
 	}
 
-	if strength && len(key) == 0 {
-		return nil, errors.New("empty key phrase not allowed")
+	return InputWithPrompt("Enter key phrase", minScore, retype)
+}
+
+// InputWithPrompt is like Input, but asks for the key phrase with the provided prompt, like "Enter new key phrase",
+// and checks that its strength score is at least minScore, unless minScore is NoStrengthCheck.
+func InputWithPrompt(prompt string, minScore int, retype bool) ([]byte, error) {
+	key, err := input(prompt + ": ")
+	if err != nil {
+		return nil, err
 	}
 
-	if strength && !verifyStrength(key) {
-		return nil, errors.New("too weak - must be at least 6 long, must have a letter, a digit and a special character")
+	if minScore != NoStrengthCheck {
+		if err := CheckStrength(key, minScore); err != nil {
+			secure.Wipe(key)
+			return nil, err
+		}
 	}
 
 	if !retype {
 		return key, nil
 	}
 
-	key2, err := input("Enter key phrase again: ")
+	key2, err := input(prompt + " again: ")
 	if err != nil {
+		secure.Wipe(key)
 		return nil, err
 	}
 
+	defer secure.Wipe(key2)
+
 	if !bytes.Equal(key, key2) {
+		secure.Wipe(key)
 		return nil, errors.New("key phrases do not match")
 	}
 
 	return key, nil
 }
 
+// input asks for the key phrase on the terminal. The prompt is written to the terminal too, or to stderr,
+// but never to stdout, which can be the decrypted data, the output of a git filter or a textconv driver.
 func input(query string) ([]byte, error) {
-	fmt.Print(query)
-	defer fmt.Println()
+	var out io.Writer = os.Stderr
 
-	key, err := term.ReadPassword(0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	fd := int(os.Stdin.Fd())
+
+	// stdin might be used for something else, like a list of files, so the terminal is used directly
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		fd = int(tty.Fd())
+		out = tty
+	} else if !term.IsTerminal(fd) {
+		return nil, ErrorNoTerminal
 	}
 
-	return key, nil
-}
+	fmt.Fprint(out, query)
+	defer fmt.Fprintln(out)
 
-func verifyStrength(key []byte) bool {
-	var (
-		keyLen      int
-		keyHasAlpha bool
-		keyHasNum   bool
-		keyHasSpec  bool
-	)
-
-	if utf8.Valid(key) {
-		for i := 0; i < len(key); {
-			r, size := utf8.DecodeRune(key[i:])
-			i += size
-
-			keyLen++
-			keyHasAlpha = keyHasAlpha || unicode.IsLetter(r)
-			keyHasNum = keyHasNum || unicode.IsNumber(r)
-			keyHasSpec = keyHasSpec || (!unicode.IsLetter(r) && !unicode.IsNumber(r))
-		}
-	} else {
-		return true
+	key, err := term.ReadPassword(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
 	}
 
-	return keyLen >= 6 && keyHasAlpha && keyHasNum && keyHasSpec
+	defer secure.Wipe(key)
+
+	return secure.LockedCopy(key), nil
 }
@@ -0,0 +1,600 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The strength of a key phrase is estimated in the style of zxcvbn: the key phrase is split into parts
+// that an attacker would guess separately, like dictionary words, sequences, keyboard patterns, repeats
+// and years, and the split that needs the fewest guesses gives the entropy. Parts that don't match
+// any pattern are guessed character by character.
+
+const (
+	MaxScore        = 4
+	DefaultMinScore = 3 // about 50 bits, like four random words from the wordlist
+)
+
+// scoreBits are the minimum entropies of scores 1 to MaxScore. Key phrases are hashed only once
+// to make the key, so guessing is fast and the scores are much stricter than the ones of zxcvbn.
+var scoreBits = [MaxScore]float64{20, 35, 50, 65}
+
+var scoreNames = [MaxScore + 1]string{"too guessable", "very weak", "weak", "good", "strong"}
+
+var ErrorTooWeak = errors.New("key phrase too weak")
+
+// Strength is the estimated strength of a key phrase.
+type Strength struct {
+	Score    int      // from 0 (too guessable) to MaxScore (strong)
+	Entropy  float64  // estimated entropy in bits
+	Feedback []string // what makes the key phrase weak and how to improve it
+}
+
+func (s Strength) String() string {
+	return fmt.Sprintf("%d of %d (%s), about %.0f bits", s.Score, MaxScore, scoreNames[s.Score], s.Entropy)
+}
+
+// Estimate estimates the strength of the key phrase. Only its first MaxKeyLen bytes make the key,
+// so the rest isn't counted. Key phrases that aren't valid UTF-8, like binary keys read from files,
+// are counted as random bytes.
+func Estimate(key []byte) Strength {
+	truncated := len(key) > MaxKeyLen
+	if truncated {
+		cut := MaxKeyLen
+		if utf8.Valid(key) {
+			for !utf8.RuneStart(key[cut]) {
+				cut--
+			}
+		}
+
+		key = key[:cut]
+	}
+
+	if !utf8.Valid(key) {
+		return newStrength(8*float64(len(key)), nil, truncated)
+	}
+
+	// the key phrase is decoded without a string, which can't be wiped
+	runes := make([]rune, 0, len(key))
+	for rest := key; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		runes = append(runes, r)
+		rest = rest[size:]
+	}
+
+	defer clear(runes)
+
+	entropy, used := minimumEntropy(runes)
+
+	return newStrength(entropy, used, truncated)
+}
+
+// CheckStrength returns an error if the key phrase is empty or if its estimated score is below minScore.
+func CheckStrength(key []byte, minScore int) error {
+	if len(key) == 0 {
+		return errors.New("empty key phrase not allowed")
+	}
+
+	s := Estimate(key)
+	if s.Score >= minScore {
+		return nil
+	}
+
+	return fmt.Errorf("%w: strength %s, at least %d needed. %s", ErrorTooWeak, s, minScore, strings.Join(s.Feedback, " "))
+}
+
+func newStrength(entropy float64, used []match, truncated bool) Strength {
+	s := Strength{Entropy: entropy}
+
+	for s.Score < MaxScore && entropy >= scoreBits[s.Score] {
+		s.Score++
+	}
+
+	s.Feedback = feedback(used, s.Score)
+
+	if truncated {
+		s.Feedback = append(s.Feedback, fmt.Sprintf("Only the first %d bytes of a key phrase are used, the rest doesn't add strength.", MaxKeyLen))
+	}
+
+	return s
+}
+
+type pattern int
+
+const (
+	patternWord pattern = iota
+	patternCommon
+	patternSequence
+	patternKeyboard
+	patternRepeat
+	patternYear
+)
+
+type match struct {
+	start, end  int // rune indexes, end is exclusive
+	entropy     float64
+	pattern     pattern
+	l33t        bool
+	capitalized bool
+}
+
+// minimumEntropy returns the entropy of the split of the key phrase with the fewest guesses and the matches used.
+func minimumEntropy(runes []rune) (float64, []match) {
+	matches := findMatches(runes)
+
+	byEnd := make(map[int][]int)
+	for i, m := range matches {
+		byEnd[m.end] = append(byEnd[m.end], i)
+	}
+
+	best := make([]float64, len(runes)+1)
+	from := make([]int, len(runes)+1) // index of the match that ends at the position, -1 for a single character
+
+	for k := 1; k <= len(runes); k++ {
+		best[k] = best[k-1] + bruteForceEntropy(runes[k-1])
+		from[k] = -1
+
+		for _, i := range byEnd[k] {
+			if e := best[matches[i].start] + matches[i].entropy; e < best[k] {
+				best[k] = e
+				from[k] = i
+			}
+		}
+	}
+
+	var used []match
+	for k := len(runes); k > 0; {
+		if from[k] < 0 {
+			k--
+			continue
+		}
+
+		m := matches[from[k]]
+		used = append(used, m)
+		k = m.start
+	}
+
+	return best[len(runes)], used
+}
+
+func findMatches(runes []rune) []match {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	defer clear(lower)
+
+	var matches []match
+
+	matches = append(matches, dictionaryMatches(runes, lower)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes, lower)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	return matches
+}
+
+const maxWordLen = 16
+
+func dictionaryMatches(runes, lower []rune) []match {
+	var matches []match
+
+	// words are looked up in a buffer large enough for any of them, so it's never replaced and it's wiped
+	buf := make([]byte, 0, maxWordLen*utf8.UTFMax)
+	defer clear(buf[:cap(buf)])
+
+	for i := range lower {
+		for j := i + 3; j <= len(lower) && j-i <= maxWordLen; j++ {
+			if m, ok := lookupWord(runes[i:j], lower[i:j], buf); ok {
+				m.start, m.end = i, j
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	// words with predictable substitutions, like p@ssw0rd; the digit 1 can stand for i or l
+	for _, one := range []rune{'i', 'l'} {
+		decoded := make([]rune, len(lower))
+		for i, r := range lower {
+			decoded[i] = unl33t(r, one)
+		}
+
+		defer clear(decoded)
+
+		for i := range decoded {
+			substituted := 0
+
+			for j := i + 1; j <= len(decoded) && j-i <= maxWordLen; j++ {
+				if decoded[j-1] != lower[j-1] {
+					substituted++
+				}
+
+				if j-i < 3 || substituted == 0 {
+					continue
+				}
+
+				if m, ok := lookupWord(runes[i:j], decoded[i:j], buf); ok {
+					m.start, m.end = i, j
+					m.entropy += float64(substituted)
+					m.l33t = true
+					matches = append(matches, m)
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// lookupWord looks the word up in the common passwords and the wordlist. The buf is used to encode the word.
+func lookupWord(original, word []rune, buf []byte) (match, bool) {
+	var m match
+
+	for _, r := range word {
+		buf = utf8.AppendRune(buf, r)
+	}
+
+	// the conversions in map indexes don't allocate strings
+	if rank, ok := commonRanks()[string(buf)]; ok {
+		m.pattern = patternCommon
+		m.entropy = math.Log2(float64(rank + 1))
+	} else if wordSet()[string(buf)] {
+		m.pattern = patternWord
+		m.entropy = math.Log2(float64(len(words())))
+	} else {
+		return match{}, false
+	}
+
+	caseBits, capitalized := caseEntropy(original)
+	m.entropy += caseBits
+	m.capitalized = capitalized
+
+	return m, true
+}
+
+// caseEntropy returns the bits added by upper case letters. Capitalizing the first letter or all of them
+// adds only one bit. It also reports whether only the first letter is capitalized.
+func caseEntropy(runes []rune) (float64, bool) {
+	var upper, lower int
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0, false
+	case lower == 0:
+		return 1, false
+	case upper == 1 && unicode.IsUpper(runes[0]):
+		return 1, true
+	}
+
+	// the number of ways to choose at most min(upper, lower) letters to change the case of
+	var variations float64
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+
+	return math.Log2(variations), false
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+
+	return result
+}
+
+func unl33t(r, one rune) rune {
+	switch r {
+	case '4', '@':
+		return 'a'
+	case '3':
+		return 'e'
+	case '1':
+		return one
+	case '!', '|':
+		return 'i'
+	case '0':
+		return 'o'
+	case '5', '$':
+		return 's'
+	case '7', '+':
+		return 't'
+	}
+
+	return r
+}
+
+// sequenceMatches finds runs of consecutive letters or digits, like abc, 6543 or XYZ.
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		if (delta != 1 && delta != -1) || sequenceClass(runes[i]) == 0 {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && runes[j]-runes[j-1] == delta && sequenceClass(runes[j]) == sequenceClass(runes[i]) {
+			j++
+		}
+
+		if j-i >= 3 {
+			first := runes[i]
+
+			var base float64
+			switch {
+			case strings.ContainsRune("aAzZ019", first):
+				base = 2
+			case unicode.IsDigit(first):
+				base = math.Log2(10)
+			default:
+				base = math.Log2(26)
+				if unicode.IsUpper(first) {
+					base++
+				}
+			}
+
+			if delta < 0 {
+				base++
+			}
+
+			matches = append(matches, match{
+				start:   i,
+				end:     j,
+				entropy: base + math.Log2(float64(j-i)),
+				pattern: patternSequence,
+			})
+		}
+
+		i = j - 1
+	}
+
+	return matches
+}
+
+func sequenceClass(r rune) int {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return 1
+	case r >= 'A' && r <= 'Z':
+		return 2
+	case r >= '0' && r <= '9':
+		return 3
+	}
+
+	return 0
+}
+
+// keyboardRows are the rows of the keyboard, each of them also reversed.
+var keyboardRows = sync.OnceValue(func() [][]rune {
+	var rows [][]rune
+
+	for _, row := range []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"} {
+		reversed := []rune(row)
+		slices.Reverse(reversed)
+
+		rows = append(rows, []rune(row), reversed)
+	}
+
+	return rows
+})
+
+// keyboardMatches finds runs of neighbouring keys in a row of the keyboard, like qwerty or lkjh.
+func keyboardMatches(runes, lower []rune) []match {
+	var matches []match
+
+	for i := range lower {
+		longest := 0
+
+		for _, keys := range keyboardRows() {
+			j := i + 1
+			for j < len(lower) && containsRunes(keys, lower[i:j+1]) {
+				j++
+			}
+
+			if containsRunes(keys, lower[i:j]) {
+				longest = max(longest, j-i)
+			}
+		}
+
+		if longest < 4 {
+			continue
+		}
+
+		caseBits, _ := caseEntropy(runes[i : i+longest])
+
+		matches = append(matches, match{
+			start:   i,
+			end:     i + longest,
+			entropy: math.Log2(47) + 1 + math.Log2(float64(longest)) + caseBits,
+			pattern: patternKeyboard,
+		})
+	}
+
+	return matches
+}
+
+const maxRepeatBlock = 32
+
+// repeatMatches finds repeated characters and blocks, like aaa or abcabc.
+func repeatMatches(runes []rune) []match {
+	var matches []match
+
+	for i := range runes {
+		for size := 1; size <= maxRepeatBlock && i+2*size <= len(runes); size++ {
+			block := runes[i : i+size]
+
+			count := 1
+			for end := i + 2*size; end <= len(runes) && slices.Equal(runes[end-size:end], block); end += size {
+				count++
+			}
+
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+
+			var blockEntropy float64
+			if size == 1 {
+				blockEntropy = bruteForceEntropy(block[0])
+			} else {
+				blockEntropy, _ = minimumEntropy(block)
+			}
+
+			matches = append(matches, match{
+				start:   i,
+				end:     i + count*size,
+				entropy: blockEntropy + math.Log2(float64(count)),
+				pattern: patternRepeat,
+			})
+		}
+	}
+
+	return matches
+}
+
+// containsRunes reports whether the sub is a part of the runes.
+func containsRunes(runes, sub []rune) bool {
+	for i := 0; i+len(sub) <= len(runes); i++ {
+		if slices.Equal(runes[i:i+len(sub)], sub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// yearMatches finds years from 1900 to 2049.
+func yearMatches(runes []rune) []match {
+	var matches []match
+
+	for i := 0; i+4 <= len(runes); i++ {
+		year := 0
+		for _, r := range runes[i : i+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+
+			year = year*10 + int(r-'0')
+		}
+
+		if year >= 1900 && year <= 2049 {
+			matches = append(matches, match{
+				start:   i,
+				end:     i + 4,
+				entropy: math.Log2(150),
+				pattern: patternYear,
+			})
+		}
+	}
+
+	return matches
+}
+
+func bruteForceEntropy(r rune) float64 {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return math.Log2(26)
+	case r >= '0' && r <= '9':
+		return math.Log2(10)
+	case r < utf8.RuneSelf:
+		return math.Log2(33)
+	default:
+		return math.Log2(100)
+	}
+}
+
+// feedback returns the messages about the patterns found in the key phrase. The examples in them are fixed,
+// never parts of the key phrase, which could end up in logs or on the screen.
+func feedback(used []match, score int) []string {
+	var messages []string
+
+	add := func(message string) {
+		for _, m := range messages {
+			if m == message {
+				return
+			}
+		}
+
+		messages = append(messages, message)
+	}
+
+	for i := len(used) - 1; i >= 0; i-- {
+		m := used[i]
+
+		switch m.pattern {
+		case patternCommon:
+			add(`Avoid common passwords, like "password".`)
+		case patternSequence:
+			add(`Avoid sequences, like "abc" or "987".`)
+		case patternKeyboard:
+			add(`Avoid keyboard patterns, like "qwerty".`)
+		case patternRepeat:
+			add(`Avoid repeated characters and words, like "aaa" or "abcabc".`)
+		case patternYear:
+			add(`Avoid years, like "1999".`)
+		}
+
+		if m.l33t {
+			add("Predictable substitutions, like @ for a, don't help much.")
+		}
+
+		if m.capitalized {
+			add("Capitalizing the first letter doesn't help much.")
+		}
+	}
+
+	if score < DefaultMinScore {
+		add("Add another word or two: random words are easier to remember than random characters. Try the genpass command.")
+	}
+
+	return messages
+}
+
+var wordSet = sync.OnceValue(func() map[string]bool {
+	set := make(map[string]bool, len(words()))
+	for _, w := range words() {
+		set[w] = true
+	}
+
+	return set
+})
+
+// commonPasswords are among the most used passwords, the most used first.
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "abc123", "123456789", "111111", "1234567", "iloveyou", "adobe123",
+	"123123", "admin", "1234567890", "letmein", "photoshop", "1234", "monkey", "shadow", "sunshine", "12345",
+	"password1", "princess", "azerty", "trustno1", "000000", "dragon", "football", "baseball", "welcome", "master",
+	"login", "passw0rd", "starwars", "hello", "freedom", "whatever", "qazwsx", "charlie", "batman", "secret",
+	"access", "flower", "superman", "michael", "jesus", "ninja", "mustang", "hunter", "soccer", "hockey",
+	"killer", "george", "andrew", "jordan", "harley", "ranger", "thomas", "tigger", "robert", "matrix",
+	"pepper", "daniel", "summer", "winter", "spring", "autumn", "changeme", "default", "root", "test",
+	"guest", "love", "god", "sex", "money", "computer", "internet", "cookie", "chocolate", "fenc",
+}
+
+var commonRanks = sync.OnceValue(func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, w := range commonPasswords {
+		ranks[w] = i + 1
+	}
+
+	return ranks
+})
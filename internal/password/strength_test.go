@@ -0,0 +1,161 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		key         string
+		expScore    int
+		expFeedback string // a part of the feedback
+	}{
+		{key: "abc12!", expScore: 0, expFeedback: `sequences, like "abc"`},
+		{key: "password", expScore: 0, expFeedback: `common passwords, like "password"`},
+		{key: "P@ssw0rd", expScore: 0, expFeedback: "substitutions"},
+		{key: "qwertyuiop", expScore: 0, expFeedback: "keyboard patterns"},
+		{key: "aaaaaaaaaaaa", expScore: 0, expFeedback: "repeated"},
+		{key: "abcabcabcabc", expScore: 0, expFeedback: "repeated"},
+		{key: "Summer2023!", expScore: 0, expFeedback: `years, like "1999"`},
+		{key: "Tr0ub4dor&3", expScore: 2, expFeedback: "Add another word"},
+		{key: "xK9#mQ2$vL7p", expScore: 3},
+		{key: "correct horse battery staple", expScore: 4},
+		{key: "\xff\xfe\x00\x01\x02\x03\x04\x05\x06\x07", expScore: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			s := Estimate([]byte(test.key))
+
+			if s.Score != test.expScore {
+				t.Errorf("score mismatch: got=%d want=%d (%s)", s.Score, test.expScore, s)
+			}
+
+			feedback := strings.Join(s.Feedback, " ")
+			if !strings.Contains(feedback, test.expFeedback) {
+				t.Errorf("feedback mismatch: got=%q want=%q", feedback, test.expFeedback)
+			}
+		})
+	}
+}
+
+func TestFeedbackHidesKey(t *testing.T) {
+	err := CheckStrength([]byte("Summer2023!zxcvbn"), DefaultMinScore)
+	if err == nil {
+		t.Errorf("weak key phrase accepted")
+		return
+	}
+
+	for _, part := range []string{"ummer", "2023", "zxcv"} {
+		if strings.Contains(err.Error(), part) {
+			t.Errorf("error contains a part of the key phrase %q: %v", part, err)
+		}
+	}
+}
+
+func TestCheckStrength(t *testing.T) {
+	if err := CheckStrength([]byte("abc12!"), DefaultMinScore); !errors.Is(err, ErrorTooWeak) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorTooWeak)
+	}
+
+	if err := CheckStrength([]byte("abc12!"), 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := CheckStrength(nil, 0); err == nil {
+		t.Errorf("empty key phrase accepted")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	phrase, entropy, err := GenerateWords(6, " ")
+	if err != nil {
+		t.Errorf("failed to generate words: %v", err)
+		return
+	}
+
+	if got := len(bytes.Split(phrase, []byte(" "))); got != 6 {
+		t.Errorf("word count mismatch: got=%d want=%d", got, 6)
+	}
+
+	// only 6 words that fit in MaxKeyLen bytes are chosen, not any 6 words with 77.5 bits
+	if entropy < 64 || entropy > 64.5 {
+		t.Errorf("entropy mismatch: got=%f want=%f", entropy, 64.2)
+	}
+
+	if s := Estimate(phrase); s.Score < DefaultMinScore {
+		t.Errorf("score mismatch: got=%d want at least %d (%q)", s.Score, DefaultMinScore, phrase)
+	}
+
+	for _, test := range []struct {
+		n   int
+		sep string
+	}{{n: 3, sep: " "}, {n: 6, sep: " "}, {n: 7, sep: "-"}, {n: 5, sep: "..."}} {
+		for range 20 {
+			phrase, _, err = GenerateWords(test.n, test.sep)
+			if err != nil {
+				t.Errorf("failed to generate %d words: %v", test.n, err)
+				return
+			}
+
+			if len(phrase) > MaxKeyLen {
+				t.Errorf("passphrase longer than %d bytes: %q", MaxKeyLen, phrase)
+			}
+
+			if got := len(bytes.Split(phrase, []byte(test.sep))); got != test.n {
+				t.Errorf("word count mismatch: got=%d want=%d", got, test.n)
+			}
+		}
+	}
+
+	chars, _, err := GenerateChars(24)
+	if err != nil {
+		t.Errorf("failed to generate chars: %v", err)
+		return
+	}
+
+	if utf8.RuneCount(chars) != 24 || bytes.ContainsRune(chars, ' ') {
+		t.Errorf("invalid generated password: %q", chars)
+	}
+
+	for _, n := range []int{0, 9} {
+		if _, _, err = GenerateWords(n, " "); !errors.Is(err, ErrorInvalidLength) {
+			t.Errorf("error mismatch for %d words: got=%v want=%v", n, err, ErrorInvalidLength)
+		}
+	}
+
+	if _, _, err = GenerateChars(MaxKeyLen + 1); !errors.Is(err, ErrorInvalidLength) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorInvalidLength)
+	}
+}
+
+func TestEstimateLong(t *testing.T) {
+	const key = "correct horse battery staple"
+
+	tests := []string{
+		key + " and some more words",
+		key + " čćžšđ",
+		key + "\xff\xfe\x00\x01\x02\x03\x04\x05\x06",
+	}
+
+	for _, long := range tests {
+		s := Estimate([]byte(long))
+		want := Estimate([]byte(long[:MaxKeyLen]))
+
+		if utf8.ValidString(long) {
+			want = Estimate([]byte(strings.ToValidUTF8(long[:MaxKeyLen], "")))
+		}
+
+		if s.Entropy != want.Entropy {
+			t.Errorf("entropy mismatch for %q: got=%f want=%f", long, s.Entropy, want.Entropy)
+		}
+
+		if feedback := strings.Join(s.Feedback, " "); !strings.Contains(feedback, "Only the first") {
+			t.Errorf("feedback mismatch: got=%q", feedback)
+		}
+	}
+}
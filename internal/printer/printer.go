@@ -17,6 +17,7 @@ const (
 	colorDst
 	colorDone
 	colorFail
+	colorSkip
 	colorErr
 	lenColors
 )
@@ -43,6 +44,7 @@ func MakePrinter(suppress, noColor bool) *Printer {
 	colors[colorDst] = color.New(color.FgCyan)
 	colors[colorDone] = color.New(color.FgGreen)
 	colors[colorFail] = color.New(color.FgRed)
+	colors[colorSkip] = color.New(color.FgYellow)
 	colors[colorErr] = color.New(color.FgHiRed)
 
 	if noColor {
@@ -114,6 +116,41 @@ func (p *Printer) PrintFail() {
 	p.colors[colorNorm].Print(" ")
 }
 
+func (p *Printer) PrintSkip() {
+	if p.suppress {
+		return
+	}
+
+	p.colors[colorNorm].Print(" ")
+	p.colors[colorSkip].Print("SKIP")
+	p.colors[colorNorm].Print(" ")
+}
+
+func (p *Printer) PrintRenamed() {
+	if p.suppress {
+		return
+	}
+
+	p.colors[colorSkip].Print("RENAMED")
+	p.colors[colorNorm].Print(" ")
+}
+
+func (p *Printer) PrintSummary(countDone, countFail, countSkip, countRenamed int) {
+	if p.suppress || countDone+countFail+countSkip <= 1 {
+		return
+	}
+
+	p.colors[colorNorm].Print("done: ")
+	p.colors[colorDone].Print(countDone)
+	p.colors[colorNorm].Print(", failed: ")
+	p.colors[colorFail].Print(countFail)
+	p.colors[colorNorm].Print(", skipped: ")
+	p.colors[colorSkip].Print(countSkip)
+	p.colors[colorNorm].Print(", renamed: ")
+	p.colors[colorSkip].Print(countRenamed)
+	p.colors[colorNorm].Println()
+}
+
 func (p *Printer) PrintError(err error, format string, args ...any) {
 	if err == nil {
 		return
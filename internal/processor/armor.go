@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/armor"
+)
+
+// Armored files are decoded in memory when they need to be read with seeking, like for rekey and upgrade,
+// and the output, which is completed with seeking to the header, is prepared in memory and armored at the end.
+
+// seekableInput returns the reader of the encrypted data of the file and whether the file is armored.
+func seekableInput(input *os.File) (io.ReadSeeker, bool, error) {
+	br := bufio.NewReader(input)
+
+	armored, err := armor.Detect(br)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !armored {
+		if _, err = input.Seek(0, io.SeekStart); err != nil {
+			return nil, false, fmt.Errorf("failed to seek: %w", err)
+		}
+
+		return input, false, nil
+	}
+
+	data, err := io.ReadAll(armor.NewReader(br))
+	if err != nil {
+		return nil, true, err
+	}
+
+	return bytes.NewReader(data), true, nil
+}
+
+// writeArmored calls write with an in-memory writer and writes its content armored to the writer.
+func writeArmored(writer io.Writer, write func(w io.WriteSeeker) error) error {
+	output := &memFile{}
+
+	if err := write(output); err != nil {
+		return err
+	}
+
+	armorWriter := armor.NewWriter(writer)
+
+	if _, err := armorWriter.Write(output.data); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	if err := armorWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	return nil
+}
+
+// IsArmoredFile reports whether the file is armored.
+func IsArmoredFile(inputFile string) (armored bool, err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("probe: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("probe: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	armored, err = armor.Detect(bufio.NewReader(input))
+
+	return
+}
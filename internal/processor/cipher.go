@@ -1,15 +1,82 @@
 package processor
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"unicode/utf8"
 
 	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/header"
+	"golang.org/x/text/unicode/norm"
 )
 
+// PhraseBlock is the cipher block made from the key phrase normalized to Unicode NFC, so that the same key phrase
+// gives the same key, no matter how its accented letters are composed by the keyboard or the input method.
+// For files encrypted before key phrases were normalized, it also keeps the block made from the key phrase
+// as it was entered.
+type PhraseBlock struct {
+	cipher.Block              // made from the normalized key phrase
+	raw          cipher.Block // made from the key phrase as entered, nil if it's already normalized
+
+	// OnFallback, if set, is called when a file encrypted before key phrases were normalized
+	// can't be decrypted with the key phrase as entered, but it can with the normalized one.
+	OnFallback func()
+}
+
+// CipherBlock returns the cipher block made from the key phrase. Copies of the key phrase and the key
+// made on the way are wiped, so the caller should wipe the key phrase as soon as it's not needed.
 func CipherBlock(keyPhrase []byte) (block cipher.Block, err error) {
-	var key []byte
+	normalized := Normalize(keyPhrase)
+	if !sameMemory(normalized, keyPhrase) {
+		defer clear(normalized)
+	}
+
+	pb := &PhraseBlock{}
+
+	pb.Block, err = phraseCipher(normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(normalized, keyPhrase) {
+		pb.raw, err = phraseCipher(keyPhrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pb, nil
+}
+
+// phraseCipher returns the AES cipher made from the key phrase and wipes the key, if it's a copy.
+// The AES cipher keeps only the expanded key.
+func phraseCipher(keyPhrase []byte) (cipher.Block, error) {
+	key := Key(keyPhrase)
+	if !sameMemory(key, keyPhrase) {
+		defer clear(key)
+	}
+
+	return aes.NewCipher(key)
+}
+
+// sameMemory reports whether the slices start at the same address, so that one isn't a copy of the other.
+func sameMemory(a, b []byte) bool {
+	return len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
+}
+
+// Normalize returns the key phrase normalized to Unicode NFC.
+// Key phrases that aren't valid UTF-8, like binary keys, are returned as they are.
+func Normalize(keyPhrase []byte) []byte {
+	if !utf8.Valid(keyPhrase) || norm.NFC.IsNormal(keyPhrase) {
+		return keyPhrase
+	}
+
+	return norm.NFC.Bytes(keyPhrase)
+}
 
+// Key returns the AES key made from the key phrase: AES-128, AES-192 or AES-256 depending on its length.
+func Key(keyPhrase []byte) (key []byte) {
 	const (
 		aes256 = aes.BlockSize * 2
 		aes192 = aes.BlockSize * 1.5
@@ -30,10 +97,33 @@ func CipherBlock(keyPhrase []byte) (block cipher.Block, err error) {
 		key = cipherio.FitToBlock(keyPhrase, aes128)
 	}
 
-	block, err = aes.NewCipher(key)
-	if err != nil {
-		return
+	return
+}
+
+// headerBlock returns the block made from the key phrase that was used for the file with the header:
+// the normalized one, unless the file was encrypted before key phrases were normalized.
+func headerBlock(block cipher.Block, h *header.Header) cipher.Block {
+	pb, ok := block.(*PhraseBlock)
+	if !ok {
+		return block
 	}
 
-	return
+	if h.IsNormalized() || pb.raw == nil {
+		return pb.Block
+	}
+
+	return pb.raw
+}
+
+// encryptionBlock returns the block used to encrypt the file with the header and marks the header
+// as encrypted with the normalized key phrase, if the block is made from a key phrase.
+func encryptionBlock(block cipher.Block, h *header.Header) cipher.Block {
+	pb, ok := block.(*PhraseBlock)
+	if !ok {
+		return block
+	}
+
+	h.SetNormalized()
+
+	return pb.Block
 }
@@ -1,8 +1,17 @@
 package processor
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
 	"strings"
 	"testing"
+
+	"github.com/marko-gacesa/fenc/internal/compress"
+	"github.com/marko-gacesa/fenc/internal/password"
 )
 
 func TestCipherBlock(t *testing.T) {
@@ -30,3 +39,147 @@ func TestCipherBlock(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyLength(t *testing.T) {
+	// the strength estimate and the generator count only the bytes that make the key
+	phrase := []byte(strings.Repeat("0123456789", 5))
+
+	if got, want := Key(phrase), phrase[:password.MaxKeyLen]; !bytes.Equal(got, want) {
+		t.Errorf("key mismatch: got=%q want=%q", got, want)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	const (
+		composed   = "Šifra-čađ-7!" // NFC
+		decomposed = "Šifra-čad̄-7!"
+	)
+
+	// decomposed is not the NFD form of composed, because đ has no decomposition, so it's built from composed
+	nfd := strings.NewReplacer("Š", "Š", "č", "č").Replace(composed)
+
+	if got := string(Normalize([]byte(nfd))); got != composed {
+		t.Errorf("normalized mismatch: got=%q want=%q", got, composed)
+		return
+	}
+
+	if binary := []byte{0xff, 'e', 0xcc, 0x81}; !bytes.Equal(Normalize(binary), binary) {
+		t.Errorf("binary key changed: got=%x want=%x", Normalize(binary), binary)
+	}
+
+	comp, _ := compress.FromName("gzip")
+
+	tests := []struct {
+		name        string
+		wrapKey     bool
+		encryptWith cipher.Block
+		decryptWith string
+		expFallback bool
+		expErr      error
+	}{
+		{
+			name:        "normalized",
+			encryptWith: mustCipherBlock(nfd),
+			decryptWith: composed,
+		},
+		{
+			name:        "old_decomposed",
+			encryptWith: mustAES(nfd),
+			decryptWith: nfd,
+		},
+		{
+			name:        "old_composed_fallback",
+			encryptWith: mustAES(composed),
+			decryptWith: nfd,
+			expFallback: true,
+		},
+		{
+			name:        "old_decomposed_mismatch",
+			encryptWith: mustAES(nfd),
+			decryptWith: composed,
+			expErr:      ErrorDecryptWrongKey,
+		},
+		{
+			name:        "normalized_wrapped_key",
+			wrapKey:     true,
+			encryptWith: mustCipherBlock(nfd),
+			decryptWith: composed,
+		},
+		{
+			name:        "old_decomposed_wrapped_key",
+			wrapKey:     true,
+			encryptWith: mustAES(nfd),
+			decryptWith: nfd,
+		},
+		{
+			name:        "old_composed_fallback_wrapped_key",
+			wrapKey:     true,
+			encryptWith: mustAES(composed),
+			decryptWith: nfd,
+			expFallback: true,
+		},
+		{
+			name:        "old_decomposed_mismatch_wrapped_key",
+			wrapKey:     true,
+			encryptWith: mustAES(nfd),
+			decryptWith: composed,
+			expErr:      ErrorDecryptWrongKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted := &seekBuffer{}
+			opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, WrapKey: test.wrapKey}
+			_, err := Encrypt(opts, test.encryptWith, []byte(testIV), strings.NewReader(loremIpsum), encrypted)
+			if err != nil {
+				t.Errorf("failed to encrypt: %v", err)
+				return
+			}
+
+			block := mustCipherBlock(test.decryptWith)
+
+			fallback := false
+			block.(*PhraseBlock).OnFallback = func() { fallback = true }
+
+			// seekable and streamed input
+			for _, reader := range []io.Reader{bytes.NewReader(encrypted.data), io.MultiReader(bytes.NewReader(encrypted.data))} {
+				fallback = false
+
+				var decrypted bytes.Buffer
+				err = Decrypt(DecryptOptions{}, block, reader, &decrypted)
+				if !errors.Is(err, test.expErr) {
+					t.Errorf("error mismatch: got=%v want=%v", err, test.expErr)
+					return
+				}
+
+				if fallback != test.expFallback {
+					t.Errorf("fallback mismatch: got=%t want=%t", fallback, test.expFallback)
+				}
+
+				if err == nil && decrypted.String() != loremIpsum {
+					t.Error("decrypted data mismatch")
+				}
+			}
+		})
+	}
+}
+
+func mustCipherBlock(keyPhrase string) cipher.Block {
+	block, err := CipherBlock([]byte(keyPhrase))
+	if err != nil {
+		panic(err)
+	}
+
+	return block
+}
+
+// mustAES returns the block made from the key phrase as it is, like before key phrases were normalized.
+func mustAES(keyPhrase string) cipher.Block {
+	block, err := aes.NewCipher(Key([]byte(keyPhrase)))
+	if err != nil {
+		panic(err)
+	}
+
+	return block
+}
@@ -1,66 +1,266 @@
 package processor
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
 	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/armor"
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/signing"
 )
 
-var ErrorDecryptWrongKey = errors.New("decrypt failed (wrong password?)")
+var (
+	ErrorDecryptWrongKey  = errors.New("decrypt failed (wrong password?)")
+	ErrorDecryptNotSigned = errors.New("decrypt failed (file is not signed)")
+	ErrorDecryptBadSigner = errors.New("decrypt failed (invalid signature or signed by someone else)")
+)
 
-func Decrypt(block cipher.Block, reader io.Reader, writer io.Writer) error {
-	h, err := header.Read(reader)
+// DecryptOptions holds the settings used for decryption.
+type DecryptOptions struct {
+	Signer ed25519.PublicKey // if set, the file must be signed with the matching private key
+}
+
+// Decrypt decrypts the data from the reader, which can be armored, see the armor package.
+// If opts.Signer is set, nothing is written to the writer before the signature is verified.
+func Decrypt(opts DecryptOptions, block cipher.Block, reader io.Reader, writer io.Writer) error {
+	// a seekable reader is kept, so that the signature can be verified in a pass over the encrypted data
+	seeker, _ := reader.(io.ReadSeeker)
+
+	var start int64
+	if seeker != nil {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seeker = nil
+		}
+	}
+
+	br := bufio.NewReader(reader)
+
+	armored, err := armor.Detect(br)
 	if err != nil {
 		return err
 	}
 
-	hasher := h.Hash()
+	if !armored {
+		if seeker == nil {
+			return decryptReader(opts, block, br, writer)
+		}
 
-	err = func() error {
-		blockMode := cipher.NewCBCDecrypter(block, h.GetIV())
-		decrypterReader := cipherio.NewBlockModeReader(blockMode, reader)
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("decrypt failed: %w", err)
+		}
+
+		return decryptReader(opts, block, seeker, writer)
+	}
+
+	armorReader := armor.NewReader(br)
+
+	if err = decryptReader(opts, block, armorReader, writer); err != nil {
+		return err
+	}
+
+	// the checksum and the end line follow the encrypted data
+	if _, err = io.Copy(io.Discard, armorReader); err != nil {
+		return fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	return nil
+}
+
+func decryptReader(opts DecryptOptions, block cipher.Block, reader io.Reader, writer io.Writer) error {
+	h, err := header.Read(reader)
+	if err != nil {
+		return err
+	}
 
-		gunzipper, err := gzip.NewReader(decrypterReader)
+	return decryptBody(opts, h, block, reader, writer)
+}
+
+// decryptBody decrypts the data that follows the already read header.
+func decryptBody(opts DecryptOptions, h *header.Header, block cipher.Block, reader io.Reader, writer io.Writer) (err error) {
+	if opts.Signer != nil {
+		reader, err = verifySigner(opts.Signer, h, reader)
 		if err != nil {
 			return err
 		}
+	}
 
-		gunzipper.Multistream(false)
+	block, reader, err = legacyBlock(h, block, reader)
+	if err != nil {
+		return err
+	}
 
-		_, err = io.Copy(io.MultiWriter(writer, hasher), gunzipper)
-		if err != nil {
-			return err
+	var sivHasher hash.Hash
+	if h.IsDeterministic() {
+		sivHasher = newSIVHasher(headerBlock(block, h))
+	}
+
+	block, err = dataBlock(block, h)
+	if err != nil {
+		return err
+	}
+
+	hasher := checksumHasher(h, block)
+
+	plainWriter := io.MultiWriter(writer, hasher)
+	if sivHasher != nil {
+		plainWriter = io.MultiWriter(writer, hasher, sivHasher)
+	}
+
+	err = func() error {
+		blockMode := cipher.NewCBCDecrypter(block, h.GetIV())
+		decrypterReader := cipherio.NewBlockModeReader(blockMode, reader)
+
+		if h.GetVersion() == 0 {
+			return decompress(h.GetCompression(), decrypterReader, plainWriter)
 		}
 
-		err = gunzipper.Close()
+		framer := newFrameReader(bufio.NewReader(decrypterReader))
+
+		err := decompress(h.GetCompression(), framer, plainWriter)
 		if err != nil {
 			return err
 		}
 
-		return err
+		return framer.finish()
 	}()
-	if err == gzip.ErrHeader || err == gzip.ErrChecksum {
+	if err == gzip.ErrHeader || err == gzip.ErrChecksum || err == errFrameCorrupted {
 		return ErrorDecryptWrongKey
 	}
 	if err != nil {
 		return fmt.Errorf("decrypt failed: %w", err)
 	}
 
-	if !bytes.Equal(h.GetHashSum(), hasher.Sum(nil)) {
+	if !hmac.Equal(h.GetHashSum(), hasher.Sum(nil)) {
+		return ErrorDecryptWrongKey
+	}
+
+	if sivHasher != nil && !hmac.Equal(h.GetIV(), sivHasher.Sum(nil)[:len(h.GetIV())]) {
 		return ErrorDecryptWrongKey
 	}
 
 	return nil
 }
 
-func DecryptToFile(block cipher.Block, inputFile, outputFile string) (err error) {
+// verifySigner verifies the sender signature before anything is decrypted, so that no data from an unverified
+// sender is released. The signature covers the header and the encrypted data, which is read twice
+// if the reader is seekable, or kept in memory until it's verified otherwise. It returns the reader
+// of the encrypted data that follows the header.
+func verifySigner(signer ed25519.PublicKey, h *header.Header, reader io.Reader) (io.Reader, error) {
+	if !h.IsSigned() {
+		return nil, ErrorDecryptNotSigned
+	}
+
+	bodyHasher := sha512.New()
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if _, err = io.Copy(bodyHasher, seeker); err != nil {
+				return nil, fmt.Errorf("decrypt failed: %w", err)
+			}
+
+			if err = signing.Verify(signer, signedMessage(h, bodyHasher), h.GetSenderSignature()); err != nil {
+				return nil, ErrorDecryptBadSigner
+			}
+
+			if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("decrypt failed: %w", err)
+			}
+
+			return seeker, nil
+		}
+	}
+
+	data, err := io.ReadAll(io.TeeReader(reader, bodyHasher))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	if err = signing.Verify(signer, signedMessage(h, bodyHasher), h.GetSenderSignature()); err != nil {
+		return nil, ErrorDecryptBadSigner
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// legacyBlock returns the block that decrypts the data of a file encrypted before key phrases were normalized,
+// if it has no wrapped key that tells which key phrase was used: the one made from the key phrase as entered
+// or the normalized one, in which case PhraseBlock.OnFallback is called. The data is decrypted without output
+// with both, if needed, so it's read again if the reader is seekable, or kept in memory otherwise.
+// It returns the reader of the encrypted data that follows the header.
+func legacyBlock(h *header.Header, block cipher.Block, reader io.Reader) (cipher.Block, io.Reader, error) {
+	pb, ok := block.(*PhraseBlock)
+	if !ok || pb.raw == nil || h.IsNormalized() || h.HasWrappedKey() {
+		return block, reader, nil
+	}
+
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypt failed: %w", err)
+		}
+
+		seeker = bytes.NewReader(data)
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	for _, candidate := range []cipher.Block{pb.raw, pb.Block} {
+		err = decryptBody(DecryptOptions{}, h, candidate, seeker, io.Discard)
+
+		if _, errSeek := seeker.Seek(start, io.SeekStart); errSeek != nil {
+			return nil, nil, fmt.Errorf("decrypt failed: %w", errSeek)
+		}
+
+		if err == ErrorDecryptWrongKey {
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if candidate == pb.Block && pb.OnFallback != nil {
+			pb.OnFallback()
+		}
+
+		return candidate, seeker, nil
+	}
+
+	return nil, nil, ErrorDecryptWrongKey
+}
+
+func decompress(comp compress.Method, reader io.Reader, writer io.Writer) error {
+	decompressor, err := comp.NewReader(reader)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, decompressor)
+	if err != nil {
+		return err
+	}
+
+	return decompressor.Close()
+}
+
+func DecryptToFile(opts DecryptOptions, block cipher.Block, inputFile, outputFile string) (err error) {
 	input, err := os.Open(inputFile)
 	if err != nil {
 		err = fmt.Errorf("decrypt: failed to open %q: %w", inputFile, err)
@@ -87,12 +287,37 @@ func DecryptToFile(block cipher.Block, inputFile, outputFile string) (err error)
 		}
 	}()
 
-	err = Decrypt(block, input, output)
+	err = Decrypt(opts, block, input, output)
 
 	return
 }
 
-func DecryptToStdOut(block cipher.Block, inputFile string) (err error) {
+// VerifyFile decrypts the file without storing the result, to check that it can be decrypted with the key.
+func VerifyFile(opts DecryptOptions, block cipher.Block, inputFile string) (err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("verify: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("verify: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	err = Decrypt(opts, block, input, io.Discard)
+
+	return
+}
+
+func DecryptToStdOut(opts DecryptOptions, block cipher.Block, inputFile string) error {
+	return DecryptToWriter(opts, block, inputFile, os.Stdout)
+}
+
+// DecryptToWriter decrypts the content of inputFile to the writer.
+func DecryptToWriter(opts DecryptOptions, block cipher.Block, inputFile string, writer io.Writer) (err error) {
 	input, err := os.Open(inputFile)
 	if err != nil {
 		err = fmt.Errorf("decrypt: failed to open %q: %w", inputFile, err)
@@ -106,7 +331,7 @@ func DecryptToStdOut(block cipher.Block, inputFile string) (err error) {
 		}
 	}()
 
-	err = Decrypt(block, input, os.Stdout)
+	err = Decrypt(opts, block, input, writer)
 
 	return
 }
@@ -2,48 +2,115 @@ package processor
 
 import (
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"crypto"
 	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
 	"strings"
 	"testing"
+
+	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/compress"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/values"
 )
 
 func TestDecrypt(t *testing.T) {
 	tests := []struct {
-		name       string
-		data       string
-		encryptKey string
-		decryptKey string
-		expErr     error
+		name        string
+		data        string
+		compression string
+		padding     string
+		encryptKey  string
+		decryptKey  string
+		expErr      error
 	}{
 		{
-			name:       "empty",
-			data:       "",
-			encryptKey: testKey,
-			decryptKey: testKey,
+			name:        "empty",
+			data:        "",
+			compression: "gzip",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
+		},
+		{
+			name:        "lorem_ipsum",
+			data:        loremIpsum,
+			compression: "gzip",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
+		},
+		{
+			name:        "wrong_pass",
+			data:        loremIpsum,
+			compression: "gzip",
+			encryptKey:  testKey,
+			decryptKey:  "a-wrong-password",
+			expErr:      ErrorDecryptWrongKey,
+		},
+		{
+			name:        "no_compression",
+			data:        strings.Repeat(loremIpsum, 500),
+			compression: "none",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
+		},
+		{
+			name:        "no_compression_wrong_pass",
+			data:        loremIpsum,
+			compression: "none",
+			encryptKey:  testKey,
+			decryptKey:  "a-wrong-password",
+			expErr:      ErrorDecryptWrongKey,
 		},
 		{
-			name:       "lorem_ipsum",
-			data:       loremIpsum,
-			encryptKey: testKey,
-			decryptKey: testKey,
+			name:        "zstd",
+			data:        strings.Repeat(loremIpsum, 500),
+			compression: "zstd",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
 		},
 		{
-			name:       "wrong_pass",
-			data:       loremIpsum,
-			encryptKey: testKey,
-			decryptKey: "a-wrong-password",
-			expErr:     ErrorDecryptWrongKey,
+			name:        "auto",
+			data:        loremIpsum,
+			compression: "auto",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
+		},
+		{
+			name:        "padding",
+			data:        loremIpsum,
+			compression: "none",
+			padding:     "block:4K",
+			encryptKey:  testKey,
+			decryptKey:  testKey,
+		},
+		{
+			name:        "padding_wrong_pass",
+			data:        loremIpsum,
+			compression: "gzip",
+			padding:     "padme",
+			encryptKey:  testKey,
+			decryptKey:  "a-wrong-password",
+			expErr:      ErrorDecryptWrongKey,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			encryptBlock, _ := aes.NewCipher([]byte(test.encryptKey))
+			comp, _ := compress.FromName(test.compression)
+			pad, _ := padding.FromName(cmp.Or(test.padding, "none"))
 			data := test.data
 
 			buf := &seekBuffer{}
-			_, err := Encrypt(uint(crypto.MD5), encryptBlock, []byte(testIV), strings.NewReader(data), buf)
+			opts := EncryptOptions{HashID: uint(crypto.MD5), Compression: comp, Padding: pad}
+			_, err := Encrypt(opts, encryptBlock, []byte(testIV), strings.NewReader(data), buf)
 			if err != nil {
 				t.Errorf("failed to prepare encrypted data: %v", err)
 				return
@@ -52,7 +119,7 @@ func TestDecrypt(t *testing.T) {
 			decryptBlock, _ := aes.NewCipher([]byte(test.decryptKey))
 
 			outputBuffer := bytes.NewBuffer(nil)
-			err = Decrypt(decryptBlock, bytes.NewReader(buf.data), outputBuffer)
+			err = Decrypt(DecryptOptions{}, decryptBlock, bytes.NewReader(buf.data), outputBuffer)
 			if got, want := err, test.expErr; got != want {
 				t.Errorf("error mismatch: got=%v want=%v", got, want)
 				return
@@ -69,3 +136,147 @@ func TestDecrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestDecryptTamperedPadding(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("gzip")
+	pad, _ := padding.FromName("block:1K")
+
+	buf := &seekBuffer{}
+	opts := EncryptOptions{HashID: uint(crypto.MD5), Compression: comp, Padding: pad}
+	_, err := Encrypt(opts, block, []byte(testIV), strings.NewReader(loremIpsum), buf)
+	if err != nil {
+		t.Errorf("failed to prepare encrypted data: %v", err)
+		return
+	}
+
+	buf.data[len(buf.data)-100] ^= 1
+
+	err = Decrypt(DecryptOptions{}, block, bytes.NewReader(buf.data), io.Discard)
+	if got, want := err, ErrorDecryptWrongKey; got != want {
+		t.Errorf("error mismatch: got=%v want=%v", got, want)
+	}
+}
+
+func TestDecryptSigned(t *testing.T) {
+	senderPublic, senderPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		signer ed25519.PrivateKey
+		verify ed25519.PublicKey
+		expErr error
+	}{
+		{
+			name:   "signed_verified",
+			signer: senderPrivate,
+			verify: senderPublic,
+		},
+		{
+			name:   "signed_not_verified",
+			signer: senderPrivate,
+		},
+		{
+			name:   "signed_by_other",
+			signer: senderPrivate,
+			verify: otherPublic,
+			expErr: ErrorDecryptBadSigner,
+		},
+		{
+			name:   "not_signed",
+			verify: senderPublic,
+			expErr: ErrorDecryptNotSigned,
+		},
+	}
+
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("gzip")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &seekBuffer{}
+			opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, Signer: test.signer}
+			_, err := Encrypt(opts, block, []byte(testIV), strings.NewReader(loremIpsum), buf)
+			if err != nil {
+				t.Errorf("failed to prepare encrypted data: %v", err)
+				return
+			}
+
+			// seekable and streamed input, nothing may be written before the signature is verified
+			for _, reader := range []io.Reader{bytes.NewReader(buf.data), io.MultiReader(bytes.NewReader(buf.data))} {
+				outputBuffer := bytes.NewBuffer(nil)
+				err = Decrypt(DecryptOptions{Signer: test.verify}, block, reader, outputBuffer)
+				if got, want := err, test.expErr; got != want {
+					t.Errorf("error mismatch: got=%v want=%v", got, want)
+					return
+				}
+
+				if err != nil {
+					if outputBuffer.Len() > 0 {
+						t.Errorf("data released before the signature was verified: %d bytes", outputBuffer.Len())
+					}
+
+					continue
+				}
+
+				if got, want := outputBuffer.String(), loremIpsum; got != want {
+					t.Errorf("data mismatch: got=%s want=%s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecryptVersion0(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+
+	encrypted, err := _produceVersion0EncryptedData(block, []byte(testIV), []byte(loremIpsum))
+	if err != nil {
+		t.Errorf("failed to prepare encrypted data: %v", err)
+		return
+	}
+
+	outputBuffer := bytes.NewBuffer(nil)
+	err = Decrypt(DecryptOptions{}, block, bytes.NewReader(encrypted), outputBuffer)
+	if err != nil {
+		t.Errorf("failed with error: %v", err)
+		return
+	}
+
+	if got, want := outputBuffer.String(), loremIpsum; got != want {
+		t.Errorf("data mismatch: got=%s want=%s", got, want)
+	}
+}
+
+// _produceVersion0EncryptedData produces data in the format of version 0:
+// a 128 byte header followed by gzip compressed data encrypted without framing.
+func _produceVersion0EncryptedData(block cipher.Block, iv, data []byte) ([]byte, error) {
+	hashSum := md5.Sum(data)
+
+	var raw [128]byte
+	copy(raw[0:4], values.Signature)
+	binary.LittleEndian.PutUint16(raw[4:6], 0)
+	binary.LittleEndian.PutUint16(raw[6:8], uint16(crypto.MD5))
+	copy(raw[8:8+len(hashSum)], hashSum[:])
+	copy(raw[72:88], iv)
+
+	buffer := bytes.NewBuffer(raw[:])
+
+	encryptWriter := cipherio.NewBlockModeWriter(cipher.NewCBCEncrypter(block, iv), buffer)
+	gzipper := gzip.NewWriter(encryptWriter)
+
+	if _, err := gzipper.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := gzipper.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := encryptWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
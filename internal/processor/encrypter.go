@@ -1,20 +1,91 @@
 package processor
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
 	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/signing"
 )
 
-func Encrypt(hashID uint, block cipher.Block, iv []byte, reader io.Reader, writer io.WriteSeeker) (*header.Header, error) {
-	h := header.New(hashID, iv)
+// EncryptOptions holds the settings that are used for encryption and stored in the header.
+type EncryptOptions struct {
+	HashID      uint
+	Compression compress.Method
+	Padding     padding.Method
+	Signer      ed25519.PrivateKey // if set, the signature of the sender is embedded in the header
+	WrapKey     bool               // if set, the data is encrypted with a random key stored in the header
+	// If set, equal content gives equal output. The IV must be obtained with SyntheticIV and WrapKey is ignored.
+	Deterministic bool
+	// If set, the output of EncryptFile and EncryptBytes is armored text, see the armor package.
+	// The encrypted data is then prepared in memory.
+	Armor bool
+}
+
+func Encrypt(opts EncryptOptions, block cipher.Block, iv []byte, reader io.Reader, writer io.WriteSeeker) (*header.Header, error) {
+	comp := opts.Compression
+	if comp.Auto {
+		bufReader := bufio.NewReaderSize(reader, compress.SampleSize)
+
+		sample, err := bufReader.Peek(compress.SampleSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("encrypt failed: %w", err)
+		}
+
+		comp = comp.Choose(sample)
+		reader = bufReader
+	}
 
-	hasher := h.Hash()
+	h := header.New(opts.HashID, comp.ID, iv)
+	block = encryptionBlock(block, h)
+
+	if opts.Deterministic {
+		h.SetDeterministic()
+
+		var err error
+
+		key := deriveKey(block, sivEncryptionLabel)
+		block, err = aes.NewCipher(key)
+		clear(key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt failed: %w", err)
+		}
+	} else if opts.WrapKey {
+		dataKey, err := newDataKey()
+		if err != nil {
+			return nil, err
+		}
+
+		defer clear(dataKey)
+
+		wrapped, err := wrapKey(block, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt failed: %w", err)
+		}
+
+		h.SetWrappedKey(wrapped)
+
+		block, err = aes.NewCipher(dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt failed: %w", err)
+		}
+	}
+
+	hasher := checksumHasher(h, block)
+	bodyHasher := sha512.New()
 
 	if err := h.Write(writer); err != nil {
 		return nil, err
@@ -22,15 +93,25 @@ func Encrypt(hashID uint, block cipher.Block, iv []byte, reader io.Reader, write
 
 	copyData := func() error {
 		blockMode := cipher.NewCBCEncrypter(block, h.GetIV())
-		encrypterWriter := cipherio.NewBlockModeWriter(blockMode, writer)
-		gzipper := gzip.NewWriter(encrypterWriter)
+		encrypterWriter := cipherio.NewBlockModeWriter(blockMode, io.MultiWriter(writer, bodyHasher))
+		framer := newFrameWriter(encrypterWriter, opts.Padding)
+
+		compressor, err := comp.NewWriter(framer)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(io.MultiWriter(compressor, hasher), reader)
+		if err != nil {
+			return err
+		}
 
-		_, err := io.Copy(io.MultiWriter(gzipper, hasher), reader)
+		err = compressor.Close()
 		if err != nil {
 			return err
 		}
 
-		err = gzipper.Close()
+		err = framer.Close()
 		if err != nil {
 			return err
 		}
@@ -48,6 +129,10 @@ func Encrypt(hashID uint, block cipher.Block, iv []byte, reader io.Reader, write
 
 	h.SetHashSum(hasher.Sum(nil))
 
+	if opts.Signer != nil {
+		h.SetSenderSignature(signing.Sign(opts.Signer, signedMessage(h, bodyHasher)))
+	}
+
 	if err := h.Update(writer); err != nil {
 		return nil, err
 	}
@@ -55,7 +140,31 @@ func Encrypt(hashID uint, block cipher.Block, iv []byte, reader io.Reader, write
 	return h, nil
 }
 
-func EncryptFile(hashID uint, block cipher.Block, inputFile, outputFile string) (err error) {
+// checksumLabel is the label of the key derived from the data block for the checksum of the plaintext.
+const checksumLabel = "fenc checksum"
+
+// checksumHasher returns the hash that computes the checksum of the plaintext stored in the header.
+// Since version 1 it's the HMAC with a key derived from the data block, so that the header, which isn't
+// encrypted, doesn't reveal the hash of the plaintext that could confirm a guess of the content.
+// Version 0 used the hash function as it is.
+func checksumHasher(h *header.Header, block cipher.Block) hash.Hash {
+	if h.GetVersion() == 0 {
+		return h.Hash()
+	}
+
+	key := deriveKey(block, checksumLabel)
+	defer clear(key)
+
+	return hmac.New(h.Hash, key)
+}
+
+// signedMessage returns the message covered by the sender signature:
+// the header followed by the digest of the encrypted data.
+func signedMessage(h *header.Header, bodyHasher hash.Hash) []byte {
+	return bodyHasher.Sum(h.SignedContent())
+}
+
+func EncryptFile(opts EncryptOptions, block cipher.Block, inputFile, outputFile string) (err error) {
 	input, err := os.Open(inputFile)
 	if err != nil {
 		err = fmt.Errorf("encrypt: failed to open %q: %w", inputFile, err)
@@ -82,7 +191,97 @@ func EncryptFile(hashID uint, block cipher.Block, inputFile, outputFile string)
 		}
 	}()
 
-	_, err = Encrypt(hashID, block, cipherio.RandIV(block), input, output)
+	iv := cipherio.RandIV(block)
+	if opts.Deterministic {
+		iv, err = SyntheticIV(block, input)
+		if err != nil {
+			return
+		}
+
+		if _, err = input.Seek(0, io.SeekStart); err != nil {
+			err = fmt.Errorf("encrypt: failed to seek %q: %w", inputFile, err)
+			return
+		}
+	}
+
+	if opts.Armor {
+		err = encryptArmored(opts, block, iv, input, output)
+		return
+	}
+
+	_, err = Encrypt(opts, block, iv, input, output)
 
 	return
 }
+
+// encryptArmored encrypts the data in memory, because the header is completed at the end,
+// and writes the result armored to the writer.
+func encryptArmored(opts EncryptOptions, block cipher.Block, iv []byte, reader io.Reader, writer io.Writer) error {
+	return writeArmored(writer, func(w io.WriteSeeker) error {
+		_, err := Encrypt(opts, block, iv, reader, w)
+		return err
+	})
+}
+
+// EncryptBytes encrypts the data and writes the result to the writer, which, unlike in Encrypt, doesn't need
+// to be seekable, because the output is prepared in memory.
+func EncryptBytes(opts EncryptOptions, block cipher.Block, data []byte, writer io.Writer) error {
+	iv := cipherio.RandIV(block)
+	if opts.Deterministic {
+		var err error
+
+		iv, err = SyntheticIV(block, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Armor {
+		return encryptArmored(opts, block, iv, bytes.NewReader(data), writer)
+	}
+
+	output := &memFile{}
+
+	if _, err := Encrypt(opts, block, iv, bytes.NewReader(data), output); err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(output.data); err != nil {
+		return fmt.Errorf("encrypt: failed to write: %w", err)
+	}
+
+	return nil
+}
+
+// memFile is an in-memory io.WriteSeeker.
+type memFile struct {
+	data []byte
+	pos  int
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if end := f.pos + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+
+	f.pos += copy(f.data[f.pos:], p)
+
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(f.pos)
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+
+	if offset < 0 || offset > int64(len(f.data)) {
+		return 0, errors.New("memory file: invalid offset")
+	}
+
+	f.pos = int(offset)
+
+	return offset, nil
+}
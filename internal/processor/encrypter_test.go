@@ -2,17 +2,24 @@ package processor
 
 import (
 	"bytes"
-	"compress/gzip"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
 
 	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/armor"
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/hashgen"
 	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/values"
 )
 
 const (
@@ -23,28 +30,53 @@ const (
 
 func TestEncrypt(t *testing.T) {
 	tests := []struct {
-		name string
-		key  []byte
-		data string
-		iv   []byte
+		name        string
+		key         []byte
+		data        string
+		iv          []byte
+		compression string
 	}{
 		{
-			name: "empty",
-			key:  []byte(testKey),
-			data: "",
-			iv:   []byte(testIV),
+			name:        "empty",
+			key:         []byte(testKey),
+			data:        "",
+			iv:          []byte(testIV),
+			compression: "gzip",
 		},
 		{
-			name: "lorem_ipsum",
-			key:  []byte(testKey),
-			data: loremIpsum,
-			iv:   []byte(testIV),
+			name:        "lorem_ipsum",
+			key:         []byte(testKey),
+			data:        loremIpsum,
+			iv:          []byte(testIV),
+			compression: "gzip",
 		},
 		{
-			name: "long_producing_small_output",
-			key:  []byte(testKey),
-			data: strings.Repeat("1234", 873) + strings.Repeat("ABC", 423) + strings.Repeat("qwerty", 653),
-			iv:   []byte(testIV),
+			name:        "long_producing_small_output",
+			key:         []byte(testKey),
+			data:        strings.Repeat("1234", 873) + strings.Repeat("ABC", 423) + strings.Repeat("qwerty", 653),
+			iv:          []byte(testIV),
+			compression: "gzip:9",
+		},
+		{
+			name:        "no_compression",
+			key:         []byte(testKey),
+			data:        loremIpsum,
+			iv:          []byte(testIV),
+			compression: "none",
+		},
+		{
+			name:        "no_compression_multiple_frames",
+			key:         []byte(testKey),
+			data:        strings.Repeat(loremIpsum, 500),
+			iv:          []byte(testIV),
+			compression: "none",
+		},
+		{
+			name:        "zstd",
+			key:         []byte(testKey),
+			data:        loremIpsum,
+			iv:          []byte(testIV),
+			compression: "zstd",
 		},
 	}
 
@@ -53,15 +85,17 @@ func TestEncrypt(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			block, _ := aes.NewCipher(test.key)
+			comp, _ := compress.FromName(test.compression)
 
-			wantBytes, err := _produceControlledEncryptedData(block, test.iv, []byte(test.data))
+			wantBytes, err := _produceControlledEncryptedData(comp, block, test.iv, []byte(test.data))
 			if err != nil {
 				t.Errorf("failed to prepare encrypted data: %v", err)
 				return
 			}
 
 			gotBuffer := &seekBuffer{}
-			h, err := Encrypt(hg.ID, block, test.iv, strings.NewReader(test.data), gotBuffer)
+			opts := EncryptOptions{HashID: hg.ID, Compression: comp}
+			h, err := Encrypt(opts, block, test.iv, strings.NewReader(test.data), gotBuffer)
 			if err != nil {
 				t.Errorf("failed to encrypt data: %v", err)
 				return
@@ -81,22 +115,68 @@ func TestEncrypt(t *testing.T) {
 	}
 }
 
-func _produceControlledEncryptedData(block cipher.Block, iv, data []byte) (output []byte, err error) {
-	gzipperBuffer := bytes.NewBuffer(nil)
-	gzipper := gzip.NewWriter(gzipperBuffer)
-	_, err = gzipper.Write(data)
+func TestEncryptPadding(t *testing.T) {
+	tests := []struct {
+		padding string
+		data    string
+		expSize int
+	}{
+		{padding: "none", data: loremIpsum, expSize: 464},
+		{padding: "block:1K", data: loremIpsum, expSize: 1024},
+		{padding: "bucket", data: strings.Repeat(loremIpsum, 5), expSize: 4096},
+		{padding: "padme", data: strings.Repeat(loremIpsum, 5), expSize: 2304},
+	}
+
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("none")
+
+	for _, test := range tests {
+		t.Run(test.padding, func(t *testing.T) {
+			pad, _ := padding.FromName(test.padding)
+
+			buf := &seekBuffer{}
+			opts := EncryptOptions{HashID: uint(crypto.MD5), Compression: comp, Padding: pad}
+			_, err := Encrypt(opts, block, []byte(testIV), strings.NewReader(test.data), buf)
+			if err != nil {
+				t.Errorf("failed to encrypt data: %v", err)
+				return
+			}
+
+			if got, want := len(buf.data)-header.Size, test.expSize; got != want {
+				t.Errorf("size mismatch: got=%d want=%d", got, want)
+			}
+		})
+	}
+}
+
+func _produceControlledEncryptedData(comp compress.Method, block cipher.Block, iv, data []byte) (output []byte, err error) {
+	compressorBuffer := bytes.NewBuffer(nil)
+	compressor, err := comp.NewWriter(compressorBuffer)
+	if err != nil {
+		return
+	}
+	_, err = compressor.Write(data)
 	if err != nil {
 		return
 	}
-	err = gzipper.Close()
+	err = compressor.Close()
 	if err != nil {
 		return
 	}
 
+	framedBuffer := bytes.NewBuffer(nil)
+	for compressed := compressorBuffer.Bytes(); len(compressed) > 0; {
+		n := min(len(compressed), frameSize)
+		_ = binary.Write(framedBuffer, binary.LittleEndian, uint32(n))
+		framedBuffer.Write(compressed[:n])
+		compressed = compressed[n:]
+	}
+	_ = binary.Write(framedBuffer, binary.LittleEndian, uint32(0))
+
 	blockMode := cipher.NewCBCEncrypter(block, iv)
 	encryptBuffer := bytes.NewBuffer(nil)
 	encryptWriter := cipherio.NewBlockModeWriter(blockMode, encryptBuffer)
-	_, err = encryptWriter.Write(gzipperBuffer.Bytes())
+	_, err = encryptWriter.Write(framedBuffer.Bytes())
 	if err != nil {
 		return
 	}
@@ -110,6 +190,126 @@ func _produceControlledEncryptedData(block cipher.Block, iv, data []byte) (outpu
 	return
 }
 
+func TestEncryptDeterministic(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("auto")
+	opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, WrapKey: true, Deterministic: true}
+
+	encrypt := func(data string) []byte {
+		buf := bytes.NewBuffer(nil)
+		if err := EncryptBytes(opts, block, []byte(data), buf); err != nil {
+			t.Errorf("failed to encrypt data: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	encrypted1 := encrypt(loremIpsum)
+	encrypted2 := encrypt(loremIpsum)
+	encrypted3 := encrypt(loremIpsum + ".")
+
+	if !bytes.Equal(encrypted1, encrypted2) {
+		t.Error("equal data encrypted differently")
+	}
+
+	if bytes.Equal(encrypted1[:header.Size], encrypted3[:header.Size]) {
+		t.Error("different data encrypted with equal headers")
+	}
+
+	outputBuffer := bytes.NewBuffer(nil)
+	if err := Decrypt(DecryptOptions{}, block, bytes.NewReader(encrypted1), outputBuffer); err != nil {
+		t.Errorf("failed to decrypt: %v", err)
+		return
+	}
+
+	if got, want := outputBuffer.String(), loremIpsum; got != want {
+		t.Errorf("data mismatch: got=%s want=%s", got, want)
+	}
+
+	// The IV is at offset 72. Modifying its 9th byte modifies the modification time in the gzip header,
+	// which is at offset 8 of the first block, after the frame length and the gzip magic bytes.
+	// Gzip ignores it, so the data and its hash are the same, but the IV no longer matches them.
+	encrypted1[72+8] ^= 1
+
+	err := Decrypt(DecryptOptions{}, block, bytes.NewReader(encrypted1), bytes.NewBuffer(nil))
+	if got, want := err, ErrorDecryptWrongKey; got != want {
+		t.Errorf("error mismatch for modified IV: got=%v want=%v", got, want)
+	}
+}
+
+func TestEncryptChecksum(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("gzip")
+
+	buf := &seekBuffer{}
+	opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp}
+	h, err := Encrypt(opts, block, []byte(testIV), strings.NewReader(loremIpsum), buf)
+	if err != nil {
+		t.Errorf("failed to encrypt data: %v", err)
+		return
+	}
+
+	// the header isn't encrypted, so it must not contain the plain hash of the data
+	plainHash := sha256.Sum256([]byte(loremIpsum))
+	if bytes.Equal(h.GetHashSum(), plainHash[:]) {
+		t.Error("header contains the plain hash of the data")
+	}
+
+	otherBlock, _ := aes.NewCipher([]byte("other-16byte-key"))
+	otherH, err := Encrypt(opts, otherBlock, []byte(testIV), strings.NewReader(loremIpsum), &seekBuffer{})
+	if err != nil {
+		t.Errorf("failed to encrypt data: %v", err)
+		return
+	}
+
+	if bytes.Equal(h.GetHashSum(), otherH.GetHashSum()) {
+		t.Error("equal checksums with different keys")
+	}
+}
+
+func TestEncryptArmored(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+	comp, _ := compress.FromName("auto")
+	opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, WrapKey: true, Armor: true}
+
+	buf := bytes.NewBuffer(nil)
+	if err := EncryptBytes(opts, block, []byte(loremIpsum), buf); err != nil {
+		t.Errorf("failed to encrypt data: %v", err)
+		return
+	}
+
+	text := buf.String()
+	if !strings.HasPrefix(text, armor.BeginLine+"\n") || !strings.HasSuffix(text, armor.EndLine+"\n") {
+		t.Errorf("not armored: %q", text)
+		return
+	}
+
+	version, ok, err := Probe(strings.NewReader(text))
+	if err != nil || !ok || version != values.Version {
+		t.Errorf("probe mismatch: got=%d,%t,%v want=%d,true,<nil>", version, ok, err, values.Version)
+	}
+
+	for _, input := range []string{text, "\n  '" + strings.ReplaceAll(text, "\n", "\n  ") + "'\n"} {
+		outputBuffer := bytes.NewBuffer(nil)
+		if err = Decrypt(DecryptOptions{}, block, strings.NewReader(input), outputBuffer); err != nil {
+			t.Errorf("failed to decrypt: %v", err)
+			continue
+		}
+
+		if got, want := outputBuffer.String(), loremIpsum; got != want {
+			t.Errorf("data mismatch: got=%s want=%s", got, want)
+		}
+	}
+
+	// the checksum line is right before the end line
+	lines := strings.Split(text, "\n")
+	lines[len(lines)-3] = "=AAAA"
+
+	err = Decrypt(DecryptOptions{}, block, strings.NewReader(strings.Join(lines, "\n")), io.Discard)
+	if !errors.Is(err, armor.ErrorChecksum) {
+		t.Errorf("error mismatch: got=%v want=%v", err, armor.ErrorChecksum)
+	}
+}
+
 type seekBuffer struct {
 	cur  int
 	size int
@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/marko-gacesa/fenc/internal/padding"
+)
+
+// Since version 1, the compressed data is split into frames before encryption. Each frame
+// starts with its length, stored as a 4-byte little-endian integer, and a frame of zero length
+// marks the end of the data. Everything after that, up to the end of the encrypted stream,
+// must be zero bytes. That's where the block cipher puts its padding, and where the optional
+// length-hiding padding goes. The padding is encrypted like the data, so it looks random,
+// and any change to it is detected because it no longer decrypts to zeros.
+
+const frameSize = 64 << 10
+
+var errFrameCorrupted = errors.New("frame corrupted")
+
+type frameWriter struct {
+	w       io.Writer
+	buf     []byte
+	pad     padding.Method
+	written int64
+}
+
+func newFrameWriter(w io.Writer, pad padding.Method) *frameWriter {
+	return &frameWriter{
+		w:   w,
+		buf: make([]byte, 4, 4+frameSize),
+		pad: pad,
+	}
+}
+
+func (fw *frameWriter) Write(data []byte) (int, error) {
+	var written int
+
+	for len(data) > 0 {
+		n := min(len(data), cap(fw.buf)-len(fw.buf))
+		fw.buf = append(fw.buf, data[:n]...)
+		data = data[n:]
+		written += n
+
+		if len(fw.buf) == cap(fw.buf) {
+			if err := fw.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+func (fw *frameWriter) flush() error {
+	if len(fw.buf) == 4 {
+		return nil
+	}
+
+	binary.LittleEndian.PutUint32(fw.buf[:4], uint32(len(fw.buf)-4))
+
+	err := fw.write(fw.buf)
+	fw.buf = fw.buf[:4]
+
+	return err
+}
+
+func (fw *frameWriter) write(data []byte) error {
+	n, err := fw.w.Write(data)
+	fw.written += int64(n)
+
+	return err
+}
+
+// Close writes the remaining data, the terminating frame and the padding.
+// It doesn't close the underlying writer.
+func (fw *frameWriter) Close() error {
+	if err := fw.flush(); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(fw.buf[:4], 0)
+
+	if err := fw.write(fw.buf[:4]); err != nil {
+		return err
+	}
+
+	zeros := fw.buf[:cap(fw.buf)]
+	clear(zeros)
+
+	for remaining := fw.pad.Size(fw.written) - fw.written; remaining > 0; {
+		n := min(remaining, int64(len(zeros)))
+		if err := fw.write(zeros[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+
+	return nil
+}
+
+type frameReader struct {
+	r         io.Reader
+	remaining int
+	done      bool
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: r}
+}
+
+func (fr *frameReader) Read(data []byte) (int, error) {
+	if fr.done {
+		return 0, io.EOF
+	}
+
+	if fr.remaining == 0 {
+		var raw [4]byte
+		if _, err := io.ReadFull(fr.r, raw[:]); err != nil {
+			return 0, errFrameCorrupted
+		}
+
+		size := binary.LittleEndian.Uint32(raw[:])
+		if size > frameSize {
+			return 0, errFrameCorrupted
+		}
+
+		if size == 0 {
+			fr.done = true
+			return 0, io.EOF
+		}
+
+		fr.remaining = int(size)
+	}
+
+	if len(data) > fr.remaining {
+		data = data[:fr.remaining]
+	}
+
+	n, err := fr.r.Read(data)
+	fr.remaining -= n
+
+	if err == io.EOF {
+		if fr.remaining > 0 {
+			return n, errFrameCorrupted
+		}
+		err = nil
+	}
+
+	return n, err
+}
+
+// finish verifies that all frames are read and that only zero bytes follow the terminating frame.
+func (fr *frameReader) finish() error {
+	if !fr.done {
+		n, err := io.Copy(io.Discard, fr)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return errFrameCorrupted
+		}
+	}
+
+	var buf [512]byte
+	for {
+		n, err := fr.r.Read(buf[:])
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return errFrameCorrupted
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/marko-gacesa/fenc/internal/header"
+)
+
+// Files can be encrypted with a random data key instead of the key derived from the key phrase.
+// The data key is stored in the header, encrypted with AES-GCM using the key derived from the key phrase.
+// Changing the key phrase then requires only the header to be written again.
+
+const dataKeySize = 32 // AES-256
+
+var wrapAdditionalData = []byte("fenc data key")
+
+func newDataKey() ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+func wrapKey(block cipher.Block, dataKey []byte) ([]byte, error) {
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := make([]byte, gcm.NonceSize(), header.WrappedKeySize)
+	if _, err = rand.Read(wrapped); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(wrapped, wrapped, dataKey, wrapAdditionalData), nil
+}
+
+func unwrapKey(block cipher.Block, wrapped []byte) ([]byte, error) {
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, sealed := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+
+	dataKey, err := gcm.Open(nil, nonce, sealed, wrapAdditionalData)
+	if err != nil {
+		return nil, ErrorDecryptWrongKey
+	}
+
+	return dataKey, nil
+}
+
+// dataBlock returns the cipher block used for the data of the file: the one made from the unwrapped
+// data key, if the header has it, the one made from the derived key in the deterministic mode, or the provided one.
+func dataBlock(block cipher.Block, h *header.Header) (cipher.Block, error) {
+	if h.IsDeterministic() {
+		key := deriveKey(headerBlock(block, h), sivEncryptionLabel)
+		defer clear(key)
+
+		return aes.NewCipher(key)
+	}
+
+	if !h.HasWrappedKey() {
+		return headerBlock(block, h), nil
+	}
+
+	dataKey, err := unwrapDataKey(block, h)
+	if err != nil {
+		return nil, err
+	}
+
+	defer clear(dataKey)
+
+	return aes.NewCipher(dataKey)
+}
+
+// unwrapDataKey unwraps the data key from the header. If the file was encrypted before key phrases were normalized,
+// but only the normalized key phrase unwraps it, the normalized one is used and PhraseBlock.OnFallback is called.
+func unwrapDataKey(block cipher.Block, h *header.Header) ([]byte, error) {
+	dataKey, err := unwrapKey(headerBlock(block, h), h.GetWrappedKey())
+	if err == nil {
+		return dataKey, nil
+	}
+
+	pb, ok := block.(*PhraseBlock)
+	if !ok || h.IsNormalized() || pb.raw == nil {
+		return nil, err
+	}
+
+	dataKey, errNormalized := unwrapKey(pb.Block, h.GetWrappedKey())
+	if errNormalized != nil {
+		return nil, err
+	}
+
+	if pb.OnFallback != nil {
+		pb.OnFallback()
+	}
+
+	return dataKey, nil
+}
@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/armor"
+	"github.com/marko-gacesa/fenc/internal/header"
+)
+
+// Probe reports whether the data starts with the fenc signature, or is armored data that does,
+// and which format version it uses.
+func Probe(r io.Reader) (version uint16, ok bool, err error) {
+	br := bufio.NewReader(r)
+
+	armored, err := armor.Detect(br)
+	if err != nil {
+		return
+	}
+
+	if !armored {
+		return header.Probe(br)
+	}
+
+	version, ok, err = header.Probe(armor.NewReader(br))
+	if err != nil {
+		// damaged armored data is still recognized as encrypted, decryption will report the problem
+		return 0, true, nil
+	}
+
+	return
+}
+
+// ProbeFile reports whether the file starts with the fenc signature, or is armored, and which format version it uses.
+func ProbeFile(inputFile string) (version uint16, ok bool, err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("probe: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("probe: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	version, ok, err = Probe(input)
+
+	return
+}
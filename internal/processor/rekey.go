@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"crypto/cipher"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/marko-gacesa/cipherio"
+	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/signing"
+)
+
+// Rekey writes the data from the reader, encrypted with the old key, to the writer, encrypted with the new key.
+//
+// If the data has a wrapped data key, only the header is changed: the data key is wrapped with the new key
+// and the encrypted data is copied as it is. Otherwise, the data is decrypted and encrypted again
+// in a single stream, with a wrapped data key, using the hash function and the compression of the original.
+// Data in the deterministic mode stays in the deterministic mode, see reencrypt.
+// The padding and the signer are taken from opts. The sender signature of the original is never kept,
+// because it doesn't cover the new header, but a new one is made if opts.Signer is set.
+//
+// It reports whether the data was encrypted again.
+func Rekey(opts EncryptOptions, oldBlock, newBlock cipher.Block, reader io.ReadSeeker, writer io.WriteSeeker) (bool, error) {
+	h, err := header.Read(reader)
+	if err != nil {
+		return false, err
+	}
+
+	if !h.HasWrappedKey() {
+		opts.HashID = h.GetHashID()
+		opts.Compression = h.GetCompression()
+		return true, reencrypt(opts, h, oldBlock, newBlock, reader, writer)
+	}
+
+	dataKey, err := unwrapDataKey(oldBlock, h)
+	if err != nil {
+		return false, err
+	}
+
+	defer clear(dataKey)
+
+	wrapped, err := wrapKey(encryptionBlock(newBlock, h), dataKey)
+	if err != nil {
+		return false, fmt.Errorf("rekey failed: %w", err)
+	}
+
+	h.ClearSenderSignature()
+	h.SetWrappedKey(wrapped)
+
+	if err = h.Write(writer); err != nil {
+		return false, err
+	}
+
+	bodyHasher := sha512.New()
+
+	if _, err = io.Copy(io.MultiWriter(writer, bodyHasher), reader); err != nil {
+		return false, fmt.Errorf("rekey failed: %w", err)
+	}
+
+	if opts.Signer == nil {
+		return false, nil
+	}
+
+	h.SetSenderSignature(signing.Sign(opts.Signer, signedMessage(h, bodyHasher)))
+
+	return false, h.Update(writer)
+}
+
+// reencrypt decrypts the data, whose header is already read, and encrypts it again with the new key,
+// in the current format, with a wrapped data key. Data encrypted in the deterministic mode stays
+// in the deterministic mode, which requires the data to be decrypted twice: first to compute the IV.
+func reencrypt(opts EncryptOptions, h *header.Header, oldBlock, newBlock cipher.Block, reader io.ReadSeeker, writer io.WriteSeeker) error {
+	opts.WrapKey = true
+	opts.Deterministic = h.IsDeterministic()
+
+	iv := cipherio.RandIV(newBlock)
+
+	if opts.Deterministic {
+		start, err := reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("rekey failed: %w", err)
+		}
+
+		sivHasher := newSIVHasher(newBlock)
+		if err = decryptBody(DecryptOptions{}, h, oldBlock, reader, sivHasher); err != nil {
+			return err
+		}
+
+		iv = sivHasher.Sum(nil)[:len(iv)]
+
+		if _, err = reader.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("rekey failed: %w", err)
+		}
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(decryptBody(DecryptOptions{}, h, oldBlock, reader, pw))
+	}()
+
+	_, err := Encrypt(opts, newBlock, iv, pr, writer)
+
+	// unblock the decrypting goroutine if encryption stopped early
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+
+	return err
+}
+
+// RekeyFile writes the content of inputFile, encrypted with the new key, to outputFile. See Rekey.
+func RekeyFile(opts EncryptOptions, oldBlock, newBlock cipher.Block, inputFile, outputFile string) (reencrypted bool, err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("rekey: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("rekey: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	output, err := os.Create(outputFile)
+	if err != nil {
+		err = fmt.Errorf("rekey: failed to create %q: %w", outputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := output.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("rekey: failed to close %q: %w", outputFile, errClose)
+		}
+	}()
+
+	reader, armored, err := seekableInput(input)
+	if err != nil {
+		err = fmt.Errorf("rekey: failed to read %q: %w", inputFile, err)
+		return
+	}
+
+	if !armored {
+		reencrypted, err = Rekey(opts, oldBlock, newBlock, reader, output)
+		return
+	}
+
+	// armored files stay armored
+	err = writeArmored(output, func(w io.WriteSeeker) (err error) {
+		reencrypted, err = Rekey(opts, oldBlock, newBlock, reader, w)
+		return
+	})
+
+	return
+}
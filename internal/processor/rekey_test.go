@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"strings"
+	"testing"
+
+	"github.com/marko-gacesa/fenc/internal/compress"
+	"github.com/marko-gacesa/fenc/internal/header"
+)
+
+func TestRekey(t *testing.T) {
+	const newKey = "another-key-0123"
+
+	tests := []struct {
+		name           string
+		wrapKey        bool
+		expReencrypted bool
+	}{
+		{
+			name:           "wrapped_key",
+			wrapKey:        true,
+			expReencrypted: false,
+		},
+		{
+			name:           "no_wrapped_key",
+			wrapKey:        false,
+			expReencrypted: true,
+		},
+	}
+
+	oldBlock, _ := aes.NewCipher([]byte(testKey))
+	newBlock, _ := aes.NewCipher([]byte(newKey))
+	comp, _ := compress.FromName("zstd")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted := &seekBuffer{}
+			opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, WrapKey: test.wrapKey}
+			_, err := Encrypt(opts, oldBlock, []byte(testIV), strings.NewReader(loremIpsum), encrypted)
+			if err != nil {
+				t.Errorf("failed to prepare encrypted data: %v", err)
+				return
+			}
+
+			rekeyed := &seekBuffer{}
+			reencrypted, err := Rekey(EncryptOptions{}, oldBlock, newBlock, bytes.NewReader(encrypted.data), rekeyed)
+			if err != nil {
+				t.Errorf("failed to rekey: %v", err)
+				return
+			}
+
+			if got, want := reencrypted, test.expReencrypted; got != want {
+				t.Errorf("reencrypted mismatch: got=%t want=%t", got, want)
+			}
+
+			if !test.expReencrypted && !bytes.Equal(encrypted.data[header.Size:], rekeyed.data[header.Size:]) {
+				t.Error("encrypted data changed")
+			}
+
+			err = Decrypt(DecryptOptions{}, oldBlock, bytes.NewReader(rekeyed.data), bytes.NewBuffer(nil))
+			if got, want := err, ErrorDecryptWrongKey; got != want {
+				t.Errorf("error mismatch for old key: got=%v want=%v", got, want)
+			}
+
+			outputBuffer := bytes.NewBuffer(nil)
+			err = Decrypt(DecryptOptions{}, newBlock, bytes.NewReader(rekeyed.data), outputBuffer)
+			if err != nil {
+				t.Errorf("failed to decrypt with new key: %v", err)
+				return
+			}
+
+			if got, want := outputBuffer.String(), loremIpsum; got != want {
+				t.Errorf("data mismatch: got=%s want=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRekeyWrongKey(t *testing.T) {
+	oldBlock, _ := aes.NewCipher([]byte(testKey))
+	wrongBlock, _ := aes.NewCipher([]byte("a-wrong-password"))
+	comp, _ := compress.FromName("gzip")
+
+	for _, wrapKey := range []bool{true, false} {
+		encrypted := &seekBuffer{}
+		opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, WrapKey: wrapKey}
+		_, _ = Encrypt(opts, oldBlock, []byte(testIV), strings.NewReader(loremIpsum), encrypted)
+
+		_, err := Rekey(EncryptOptions{}, wrongBlock, oldBlock, bytes.NewReader(encrypted.data), &seekBuffer{})
+		if err == nil {
+			t.Errorf("rekey with wrong key succeeded, wrapKey=%t", wrapKey)
+		}
+	}
+}
+
+func TestRekeyDeterministic(t *testing.T) {
+	const newKey = "another-key-0123"
+
+	oldBlock, _ := aes.NewCipher([]byte(testKey))
+	newBlock, _ := aes.NewCipher([]byte(newKey))
+	comp, _ := compress.FromName("gzip")
+	opts := EncryptOptions{HashID: uint(crypto.SHA256), Compression: comp, Deterministic: true}
+
+	encrypted := bytes.NewBuffer(nil)
+	if err := EncryptBytes(opts, oldBlock, []byte(loremIpsum), encrypted); err != nil {
+		t.Errorf("failed to prepare encrypted data: %v", err)
+		return
+	}
+
+	rekeyed := &seekBuffer{}
+	if _, err := Rekey(EncryptOptions{}, oldBlock, newBlock, bytes.NewReader(encrypted.Bytes()), rekeyed); err != nil {
+		t.Errorf("failed to rekey: %v", err)
+		return
+	}
+
+	expected := bytes.NewBuffer(nil)
+	if err := EncryptBytes(opts, newBlock, []byte(loremIpsum), expected); err != nil {
+		t.Errorf("failed to prepare expected data: %v", err)
+		return
+	}
+
+	if !bytes.Equal(rekeyed.data, expected.Bytes()) {
+		t.Error("rekeyed data differs from data encrypted with the new key")
+	}
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// In the deterministic mode the IV is not random, but derived from the content, similar to AES-SIV:
+// it's the truncated HMAC-SHA256 of the plaintext. Equal content encrypted with the same key phrase
+// gives equal output, which is required for version control and deduplication, but it reveals that
+// two files are equal. The data is encrypted with a key derived from the key phrase, different from
+// the key of the HMAC, and there is no wrapped data key. On decryption the IV is computed again and compared.
+
+const (
+	sivMACLabel        = "fenc siv mac"
+	sivEncryptionLabel = "fenc siv enc"
+)
+
+// deriveKey derives a 32 byte key from the cipher block, using AES as a pseudo-random function on the label.
+func deriveKey(block cipher.Block, label string) []byte {
+	key := make([]byte, 2*aes.BlockSize)
+
+	for i := 0; i < 2; i++ {
+		var in [aes.BlockSize]byte
+		copy(in[:aes.BlockSize-1], label)
+		in[aes.BlockSize-1] = byte(i + 1)
+
+		block.Encrypt(key[i*aes.BlockSize:(i+1)*aes.BlockSize], in[:])
+	}
+
+	return key
+}
+
+// newSIVHasher returns the hash that computes the synthetic IV from the plaintext.
+func newSIVHasher(block cipher.Block) hash.Hash {
+	key := deriveKey(block, sivMACLabel)
+	defer clear(key)
+
+	return hmac.New(sha256.New, key)
+}
+
+// SyntheticIV returns the IV for the deterministic mode, computed from the whole content of the reader.
+func SyntheticIV(block cipher.Block, reader io.Reader) ([]byte, error) {
+	hasher := newSIVHasher(block)
+
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return nil, fmt.Errorf("failed to compute synthetic IV: %w", err)
+	}
+
+	return hasher.Sum(nil)[:aes.BlockSize], nil
+}
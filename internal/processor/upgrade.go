@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/armor"
+	"github.com/marko-gacesa/fenc/internal/hashgen"
+	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+// Outdated returns the reasons why the data with the header should be upgraded to the current format.
+// It returns nil if the data is up to date.
+func Outdated(h *header.Header) []string {
+	var reasons []string
+
+	if v := h.GetVersion(); v < values.Version {
+		reasons = append(reasons, fmt.Sprintf("format version %d", v))
+	}
+
+	if hg, err := hashgen.FromID(h.GetHashID()); err == nil && hg.Weak() {
+		reasons = append(reasons, fmt.Sprintf("deprecated hash function %s", hg.Name))
+	}
+
+	if !h.HasWrappedKey() && !h.IsDeterministic() {
+		reasons = append(reasons, "no wrapped data key")
+	}
+
+	return reasons
+}
+
+// Upgrade decrypts the data from the reader and writes it to the writer encrypted again with the same key,
+// in the current format, with a wrapped data key, or in the deterministic mode if the original used it.
+// The compression of the original is kept.
+// The hash function of the original is kept too, unless it's deprecated, in which case opts.HashID is used.
+// The padding and the signer are taken from opts. The sender signature of the original is never kept.
+func Upgrade(opts EncryptOptions, block cipher.Block, reader io.ReadSeeker, writer io.WriteSeeker) error {
+	h, err := header.Read(reader)
+	if err != nil {
+		return err
+	}
+
+	if hg, err := hashgen.FromID(h.GetHashID()); err == nil && !hg.Weak() {
+		opts.HashID = h.GetHashID()
+	}
+
+	opts.Compression = h.GetCompression()
+
+	return reencrypt(opts, h, block, block, reader, writer)
+}
+
+// ReadHeaderFile reads only the header of the encrypted file.
+func ReadHeaderFile(inputFile string) (h *header.Header, err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("read header: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("read header: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	br := bufio.NewReader(input)
+
+	armored, err := armor.Detect(br)
+	if err != nil {
+		return
+	}
+
+	if armored {
+		h, err = header.Read(armor.NewReader(br))
+	} else {
+		h, err = header.Read(br)
+	}
+
+	return
+}
+
+// UpgradeFile writes the content of inputFile, in the current format, to outputFile. See Upgrade.
+func UpgradeFile(opts EncryptOptions, block cipher.Block, inputFile, outputFile string) (err error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		err = fmt.Errorf("upgrade: failed to open %q: %w", inputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := input.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("upgrade: failed to close %q: %w", inputFile, errClose)
+		}
+	}()
+
+	output, err := os.Create(outputFile)
+	if err != nil {
+		err = fmt.Errorf("upgrade: failed to create %q: %w", outputFile, err)
+		return
+	}
+
+	defer func() {
+		errClose := output.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("upgrade: failed to close %q: %w", outputFile, errClose)
+		}
+	}()
+
+	reader, armored, err := seekableInput(input)
+	if err != nil {
+		err = fmt.Errorf("upgrade: failed to read %q: %w", inputFile, err)
+		return
+	}
+
+	if !armored {
+		err = Upgrade(opts, block, reader, output)
+		return
+	}
+
+	// armored files stay armored
+	err = writeArmored(output, func(w io.WriteSeeker) error {
+		return Upgrade(opts, block, reader, w)
+	})
+
+	return
+}
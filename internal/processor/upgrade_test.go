@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"testing"
+
+	"github.com/marko-gacesa/fenc/internal/header"
+	"github.com/marko-gacesa/fenc/internal/values"
+)
+
+func TestUpgradeVersion0(t *testing.T) {
+	block, _ := aes.NewCipher([]byte(testKey))
+
+	encrypted, err := _produceVersion0EncryptedData(block, []byte(testIV), []byte(loremIpsum))
+	if err != nil {
+		t.Errorf("failed to prepare encrypted data: %v", err)
+		return
+	}
+
+	h, err := header.Read(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Errorf("failed to read header: %v", err)
+		return
+	}
+
+	if got, want := len(Outdated(h)), 3; got != want {
+		t.Errorf("outdated reasons mismatch: got=%v want=%d reasons", Outdated(h), want)
+	}
+
+	upgraded := &seekBuffer{}
+	err = Upgrade(EncryptOptions{HashID: uint(crypto.SHA256)}, block, bytes.NewReader(encrypted), upgraded)
+	if err != nil {
+		t.Errorf("failed to upgrade: %v", err)
+		return
+	}
+
+	h, err = header.Read(bytes.NewReader(upgraded.data))
+	if err != nil {
+		t.Errorf("failed to read upgraded header: %v", err)
+		return
+	}
+
+	if got, want := h.GetVersion(), uint16(values.Version); got != want {
+		t.Errorf("version mismatch: got=%d want=%d", got, want)
+	}
+
+	if got, want := h.GetHashID(), uint(crypto.SHA256); got != want {
+		t.Errorf("hash mismatch: got=%d want=%d", got, want)
+	}
+
+	if reasons := Outdated(h); reasons != nil {
+		t.Errorf("upgraded data is outdated: %v", reasons)
+	}
+
+	outputBuffer := bytes.NewBuffer(nil)
+	err = Decrypt(DecryptOptions{}, block, bytes.NewReader(upgraded.data), outputBuffer)
+	if err != nil {
+		t.Errorf("failed to decrypt upgraded data: %v", err)
+		return
+	}
+
+	if got, want := outputBuffer.String(), loremIpsum; got != want {
+		t.Errorf("data mismatch: got=%s want=%s", got, want)
+	}
+}
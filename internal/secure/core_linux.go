@@ -0,0 +1,23 @@
+//go:build linux
+
+package secure
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps prevents core dumps of the process, which would write key material to disk.
+// It also prevents other processes of the same user from attaching to the process with ptrace.
+func DisableCoreDumps() error {
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	return nil
+}
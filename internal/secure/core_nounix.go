@@ -0,0 +1,8 @@
+//go:build !unix
+
+package secure
+
+// DisableCoreDumps does nothing on systems without core dumps.
+func DisableCoreDumps() error {
+	return nil
+}
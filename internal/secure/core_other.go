@@ -0,0 +1,18 @@
+//go:build unix && !linux
+
+package secure
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps prevents core dumps of the process, which would write key material to disk.
+func DisableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	return nil
+}
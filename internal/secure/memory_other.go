@@ -0,0 +1,8 @@
+//go:build !unix
+
+package secure
+
+// Alloc returns a new buffer. Memory can't be locked on this system.
+func Alloc(size int) []byte {
+	return make([]byte, size)
+}
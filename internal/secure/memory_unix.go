@@ -0,0 +1,18 @@
+//go:build unix
+
+package secure
+
+import "golang.org/x/sys/unix"
+
+// Alloc returns a buffer in memory that is locked, so it's never swapped to disk.
+// Locking is best effort: it can fail because of the limit of locked memory.
+// The memory stays locked until fenc exits: memory is locked in whole pages, which can be shared
+// with other locked buffers, so unlocking the pages of one buffer could unlock the others.
+func Alloc(size int) []byte {
+	locked := make([]byte, size)
+	if size > 0 {
+		_ = unix.Mlock(locked)
+	}
+
+	return locked
+}
@@ -0,0 +1,26 @@
+package secure
+
+// Key material is kept in byte slices, never in strings, which can't be wiped. Buffers with key material
+// are allocated with Alloc, or copied to one with LockedCopy, and wiped with Wipe as soon as they aren't needed.
+
+// LockedCopy returns a copy of the data in locked memory. See Alloc.
+func LockedCopy(data []byte) []byte {
+	locked := Alloc(len(data))
+	copy(locked, data)
+
+	return locked
+}
+
+// LockedString returns a copy of the string in locked memory. It's for key material that comes as a string,
+// like an environment variable. The string itself can't be wiped.
+func LockedString(s string) []byte {
+	locked := Alloc(len(s))
+	copy(locked, s)
+
+	return locked
+}
+
+// Wipe overwrites the data with zeros. Locked memory stays locked, see Alloc.
+func Wipe(data []byte) {
+	clear(data)
+}
@@ -0,0 +1,33 @@
+package secure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLockedCopy(t *testing.T) {
+	data := []byte("key material")
+
+	locked := LockedCopy(data)
+	if !bytes.Equal(locked, data) {
+		t.Errorf("copy mismatch: got=%q want=%q", locked, data)
+	}
+
+	Wipe(locked)
+	if !bytes.Equal(locked, make([]byte, len(data))) {
+		t.Errorf("wiped data not zero: %q", locked)
+	}
+
+	if got := LockedString("phrase"); string(got) != "phrase" {
+		t.Errorf("copy mismatch: got=%q want=%q", got, "phrase")
+	}
+
+	Wipe(nil)
+	Wipe(Alloc(0))
+}
+
+func TestDisableCoreDumps(t *testing.T) {
+	if err := DisableCoreDumps(); err != nil {
+		t.Errorf("failed to disable core dumps: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir's secret sharing over GF(256): each byte of the secret is the constant term of a random polynomial
+// of degree threshold-1 and each share holds the values of all polynomials at its own, non-zero x.
+// Any threshold shares determine the polynomials, fewer shares reveal nothing about the secret.
+
+// MaxShares is the maximum number of shares, limited by the number of non-zero elements of GF(256).
+const MaxShares = 255
+
+var (
+	ErrorInvalidParams  = errors.New("shamir: invalid number of shares or threshold")
+	ErrorNoShares       = errors.New("shamir: no shares")
+	ErrorDuplicateShare = errors.New("shamir: duplicate share")
+	ErrorShareSize      = errors.New("shamir: shares have different sizes")
+)
+
+// Share is a single share of the secret: the values of the polynomials at X.
+type Share struct {
+	X byte
+	Y []byte
+}
+
+// Split splits the secret into n shares, any threshold of which rebuild it.
+func Split(secret []byte, n, threshold int) ([]Share, error) {
+	if threshold < 2 || n < threshold || n > MaxShares || len(secret) == 0 {
+		return nil, ErrorInvalidParams
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Y: make([]byte, len(secret))}
+	}
+
+	coefficients := make([]byte, threshold)
+	defer clear(coefficients)
+
+	for i, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("shamir: failed to generate coefficients: %w", err)
+		}
+
+		for j := range shares {
+			shares[j].Y[i] = evaluate(coefficients, shares[j].X)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine rebuilds the secret from the shares. It can't detect that there are fewer shares than the threshold,
+// or that the shares belong to different secrets: the result is then a wrong secret.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrorNoShares
+	}
+
+	size := len(shares[0].Y)
+
+	for i, s := range shares {
+		if s.X == 0 || len(s.Y) != size {
+			return nil, ErrorShareSize
+		}
+
+		for _, other := range shares[:i] {
+			if s.X == other.X {
+				return nil, ErrorDuplicateShare
+			}
+		}
+	}
+
+	secret := make([]byte, size)
+
+	// Lagrange interpolation at x=0. In GF(256) subtraction is the same as addition, which is xor.
+	for i, s := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = mul(basis, div(other.X, other.X^s.X))
+			}
+		}
+
+		for k, y := range s.Y {
+			secret[k] ^= mul(y, basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// evaluate returns the value of the polynomial at x, using Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+
+	return y
+}
+
+// Multiplication and division use the tables of logarithms and exponents of the generator 3,
+// with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+var (
+	logTable [256]byte
+	expTable [510]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+
+		// multiply by the generator 3: x*2 + x
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if b == 0 {
+		panic("shamir: division by zero")
+	}
+
+	if a == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
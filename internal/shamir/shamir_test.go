@@ -0,0 +1,91 @@
+package shamir
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("the secret master key of 32 byte")
+
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+		use       []int // indexes of shares used to rebuild the secret
+		expEqual  bool
+	}{
+		{name: "3_of_5", n: 5, threshold: 3, use: []int{0, 2, 4}, expEqual: true},
+		{name: "3_of_5_other", n: 5, threshold: 3, use: []int{3, 1, 2}, expEqual: true},
+		{name: "3_of_5_all", n: 5, threshold: 3, use: []int{0, 1, 2, 3, 4}, expEqual: true},
+		{name: "3_of_5_too_few", n: 5, threshold: 3, use: []int{0, 4}, expEqual: false},
+		{name: "2_of_2", n: 2, threshold: 2, use: []int{1, 0}, expEqual: true},
+		{name: "255", n: 255, threshold: 10, use: []int{254, 100, 7, 8, 9, 10, 50, 60, 70, 80}, expEqual: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := Split(secret, test.n, test.threshold)
+			if err != nil {
+				t.Errorf("failed to split: %v", err)
+				return
+			}
+
+			if len(shares) != test.n {
+				t.Errorf("share count mismatch: got=%d want=%d", len(shares), test.n)
+				return
+			}
+
+			var used []Share
+			for _, i := range test.use {
+				used = append(used, shares[i])
+			}
+
+			got, err := Combine(used)
+			if err != nil {
+				t.Errorf("failed to combine: %v", err)
+				return
+			}
+
+			if equal := bytes.Equal(got, secret); equal != test.expEqual {
+				t.Errorf("secret mismatch: got=%q want=%q", got, secret)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := Split([]byte("x"), 3, 1); !errors.Is(err, ErrorInvalidParams) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorInvalidParams)
+	}
+
+	if _, err := Split([]byte("x"), 2, 3); !errors.Is(err, ErrorInvalidParams) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorInvalidParams)
+	}
+
+	if _, err := Split([]byte("x"), 256, 3); !errors.Is(err, ErrorInvalidParams) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorInvalidParams)
+	}
+
+	shares, _ := Split([]byte("x"), 3, 2)
+
+	if _, err := Combine([]Share{shares[0], shares[0]}); !errors.Is(err, ErrorDuplicateShare) {
+		t.Errorf("error mismatch: got=%v want=%v", err, ErrorDuplicateShare)
+	}
+}
+
+func TestField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if got := div(mul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("division mismatch: a=%d b=%d got=%d", a, b, got)
+			}
+		}
+	}
+
+	// a known product in the AES field
+	if got := mul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("product mismatch: got=%#x want=%#x", got, 0xc1)
+	}
+}
@@ -0,0 +1,212 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Signatures use Ed25519ctx, with a different context for embedded and for detached signatures,
+// so that a signature of one kind can't be presented as a signature of the other.
+const (
+	contextEmbedded = "fenc embedded signature"
+	contextDetached = "fenc detached signature"
+)
+
+const (
+	pemTypePrivateKey = "PRIVATE KEY"
+	pemTypePublicKey  = "PUBLIC KEY"
+	pemTypeSignature  = "FENC SIGNATURE"
+)
+
+// SignatureExtension is the extension of detached signature files.
+const SignatureExtension = ".sig"
+
+var ErrorInvalidSignature = errors.New("invalid signature")
+
+// Sign returns the embedded signature of the message.
+func Sign(key ed25519.PrivateKey, message []byte) []byte {
+	sig, err := key.Sign(nil, message, &ed25519.Options{Context: contextEmbedded})
+	if err != nil {
+		panic(err) // can only fail for invalid options
+	}
+
+	return sig
+}
+
+// Verify checks the embedded signature of the message.
+func Verify(key ed25519.PublicKey, message, sig []byte) error {
+	err := ed25519.VerifyWithOptions(key, message, sig, &ed25519.Options{Context: contextEmbedded})
+	if err != nil {
+		return ErrorInvalidSignature
+	}
+
+	return nil
+}
+
+// SignDetached returns the detached signature of the data read from r.
+func SignDetached(key ed25519.PrivateKey, r io.Reader) ([]byte, error) {
+	digest, err := digestOf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := key.Sign(nil, digest, &ed25519.Options{Hash: crypto.SHA512, Context: contextDetached})
+	if err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// VerifyDetached checks the detached signature of the data read from r.
+func VerifyDetached(key ed25519.PublicKey, r io.Reader, sig []byte) error {
+	digest, err := digestOf(r)
+	if err != nil {
+		return err
+	}
+
+	err = ed25519.VerifyWithOptions(key, digest, sig, &ed25519.Options{Hash: crypto.SHA512, Context: contextDetached})
+	if err != nil {
+		return ErrorInvalidSignature
+	}
+
+	return nil
+}
+
+func digestOf(r io.Reader) ([]byte, error) {
+	hasher := sha512.New()
+
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, fmt.Errorf("failed to read signed data: %w", err)
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+// GenerateKey generates a new key pair and stores it to two files: the private key
+// to the file with the provided name and the public key to the same file with the ".pub" extension.
+func GenerateKey(fileName string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	rawPrivate, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	rawPublic, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	err = writePEM(fileName, pemTypePrivateKey, rawPrivate, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return writePEM(fileName+".pub", pemTypePublicKey, rawPublic, 0o644)
+}
+
+// ReadPrivateKey reads a PEM encoded Ed25519 private key.
+func ReadPrivateKey(fileName string) (ed25519.PrivateKey, error) {
+	raw, err := readPEM(fileName, pemTypePrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %q: %w", fileName, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 private key: %q", fileName)
+	}
+
+	return privateKey, nil
+}
+
+// ReadPublicKey reads a PEM encoded Ed25519 public key.
+func ReadPublicKey(fileName string) (ed25519.PublicKey, error) {
+	raw, err := readPEM(fileName, pemTypePublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %q: %w", fileName, err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 public key: %q", fileName)
+	}
+
+	return publicKey, nil
+}
+
+// WriteSignature stores a detached signature to a PEM encoded file.
+func WriteSignature(fileName string, sig []byte) error {
+	return writePEM(fileName, pemTypeSignature, sig, 0o644)
+}
+
+// ReadSignature reads a detached signature from a PEM encoded file.
+func ReadSignature(fileName string) ([]byte, error) {
+	sig, err := readPEM(fileName, pemTypeSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature size in %q", fileName)
+	}
+
+	return sig, nil
+}
+
+func writePEM(fileName, pemType string, data []byte, perm os.FileMode) (err error) {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", fileName, err)
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = fmt.Errorf("failed to close %q: %w", fileName, errClose)
+		}
+	}()
+
+	err = pem.Encode(f, &pem.Block{Type: pemType, Bytes: data})
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", fileName, err)
+	}
+
+	return nil
+}
+
+func readPEM(fileName, pemType string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", fileName, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("no %s found in %q", pemType, fileName)
+	}
+
+	return block.Bytes, nil
+}
@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetached(t *testing.T) {
+	const data = "release artifact"
+
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	sig, err := SignDetached(privateKey, strings.NewReader(data))
+	if err != nil {
+		t.Errorf("failed to sign: %v", err)
+		return
+	}
+
+	if err = VerifyDetached(publicKey, strings.NewReader(data), sig); err != nil {
+		t.Errorf("failed to verify: %v", err)
+	}
+
+	if err = VerifyDetached(publicKey, strings.NewReader(data+"!"), sig); err != ErrorInvalidSignature {
+		t.Errorf("error mismatch for modified data: got=%v want=%v", err, ErrorInvalidSignature)
+	}
+
+	if err = Verify(publicKey, []byte(data), sig); err != ErrorInvalidSignature {
+		t.Errorf("detached signature accepted as embedded: got=%v want=%v", err, ErrorInvalidSignature)
+	}
+}
+
+func TestKeyFiles(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "key")
+
+	if err := GenerateKey(fileName); err != nil {
+		t.Errorf("failed to generate key: %v", err)
+		return
+	}
+
+	privateKey, err := ReadPrivateKey(fileName)
+	if err != nil {
+		t.Errorf("failed to read private key: %v", err)
+		return
+	}
+
+	publicKey, err := ReadPublicKey(fileName + ".pub")
+	if err != nil {
+		t.Errorf("failed to read public key: %v", err)
+		return
+	}
+
+	if !publicKey.Equal(privateKey.Public()) {
+		t.Error("public key doesn't match the private key")
+	}
+
+	if _, err = ReadPublicKey(fileName); err == nil {
+		t.Error("private key accepted as public key")
+	}
+}
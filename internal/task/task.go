@@ -1,9 +1,95 @@
 package task
 
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/marko-gacesa/fenc/internal/file"
+)
+
 type Task struct {
 	ProcEnc     bool
 	InputFile   string
 	OutputFile  string
 	ToStdout    bool
 	RemoveInput bool
+	Skip        bool // the output file already exists and the task should not run
+	Overwrite   bool // the output file already exists and should be replaced
+	Renamed     bool // the output file got a new name because the original one was taken
+}
+
+// CollisionPolicy defines what happens to a task whose output file already exists.
+type CollisionPolicy int
+
+const (
+	CollisionFail CollisionPolicy = iota
+	CollisionSkip
+	CollisionOverwrite
+	CollisionRename
+)
+
+var ErrorUnsupportedCollisionPolicy = errors.New("unsupported collision policy")
+
+func CollisionPolicyFromName(name string) (p CollisionPolicy, err error) {
+	switch name {
+	case "fail":
+		p = CollisionFail
+	case "skip":
+		p = CollisionSkip
+	case "overwrite":
+		p = CollisionOverwrite
+	case "rename":
+		p = CollisionRename
+	default:
+		err = ErrorUnsupportedCollisionPolicy
+	}
+
+	return
+}
+
+// ResolveCollision applies the collision policy to the task if its output file already exists
+// or if it's already used as the output file of another task. The outputs map the cleaned output files
+// of the other tasks to their input files.
+func (t *Task) ResolveCollision(policy CollisionPolicy, outputs map[string]string) error {
+	exists, err := file.Exists(t.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	inputFile, taken := outputs[filepath.Clean(t.OutputFile)]
+	if taken && policy != CollisionRename {
+		return fmt.Errorf("files %q and %q have the same output file: %q", inputFile, t.InputFile, t.OutputFile)
+	}
+
+	if !exists && !taken {
+		return nil
+	}
+
+	switch policy {
+	case CollisionSkip:
+		t.Skip = true
+		t.RemoveInput = false
+	case CollisionOverwrite:
+		t.Overwrite = true
+	case CollisionRename:
+		for n := 1; ; n++ {
+			outputFile := file.Numbered(t.OutputFile, n)
+
+			exists, err = file.Exists(outputFile)
+			if err != nil {
+				return err
+			}
+
+			if _, taken = outputs[filepath.Clean(outputFile)]; !exists && !taken {
+				t.OutputFile = outputFile
+				t.Renamed = true
+				break
+			}
+		}
+	default:
+		return file.MustNotExist(t.OutputFile)
+	}
+
+	return nil
 }
@@ -0,0 +1,164 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveCollision(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     []string // files in the directory
+		outputs      []string // output files of other tasks
+		outputFile   string
+		policy       CollisionPolicy
+		expErr       bool
+		expOutput    string
+		expSkip      bool
+		expOverwrite bool
+		expRenamed   bool
+	}{
+		{
+			name:       "no_collision",
+			existing:   []string{"b.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionFail,
+			expOutput:  "a.txt",
+		},
+		{
+			name:       "exists_fail",
+			existing:   []string{"a.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionFail,
+			expErr:     true,
+		},
+		{
+			name:       "exists_skip",
+			existing:   []string{"a.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionSkip,
+			expOutput:  "a.txt",
+			expSkip:    true,
+		},
+		{
+			name:         "exists_overwrite",
+			existing:     []string{"a.txt"},
+			outputFile:   "a.txt",
+			policy:       CollisionOverwrite,
+			expOutput:    "a.txt",
+			expOverwrite: true,
+		},
+		{
+			name:       "exists_rename",
+			existing:   []string{"a.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionRename,
+			expOutput:  "a (1).txt",
+			expRenamed: true,
+		},
+		{
+			name:       "numbered_exists_rename",
+			existing:   []string{"a.txt", "a (1).txt", "a (2).txt"},
+			outputFile: "a.txt",
+			policy:     CollisionRename,
+			expOutput:  "a (3).txt",
+			expRenamed: true,
+		},
+		{
+			name:       "dot_file_rename",
+			existing:   []string{".env", ".env (1)"},
+			outputFile: ".env",
+			policy:     CollisionRename,
+			expOutput:  ".env (2)",
+			expRenamed: true,
+		},
+		{
+			name:       "fenc_only_rename",
+			existing:   []string{".fenc"},
+			outputFile: ".fenc",
+			policy:     CollisionRename,
+			expOutput:  ".fenc (1)",
+			expRenamed: true,
+		},
+		{
+			name:       "taken_fail",
+			outputs:    []string{"a.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionFail,
+			expErr:     true,
+		},
+		{
+			name:       "taken_overwrite",
+			outputs:    []string{"a.txt"},
+			outputFile: "a.txt",
+			policy:     CollisionOverwrite,
+			expErr:     true,
+		},
+		{
+			name:       "taken_rename",
+			existing:   []string{"a (1).txt"},
+			outputs:    []string{"a.txt", "a (2).txt"},
+			outputFile: "a.txt",
+			policy:     CollisionRename,
+			expOutput:  "a (3).txt",
+			expRenamed: true,
+		},
+		{
+			name:       "directory",
+			existing:   []string{"a.txt/"},
+			outputFile: "a.txt",
+			policy:     CollisionOverwrite,
+			expErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for _, name := range test.existing {
+				var err error
+				if name, isDir := strings.CutSuffix(name, "/"); isDir {
+					err = os.Mkdir(filepath.Join(dir, name), 0o700)
+				} else {
+					err = os.WriteFile(filepath.Join(dir, name), []byte("data"), 0o600)
+				}
+				if err != nil {
+					t.Fatalf("failed to prepare file: %v", err)
+				}
+			}
+
+			outputs := map[string]string{}
+			for _, name := range test.outputs {
+				outputs[filepath.Join(dir, name)] = filepath.Join(dir, "other")
+			}
+
+			task := Task{InputFile: filepath.Join(dir, "input"), OutputFile: filepath.Join(dir, test.outputFile), RemoveInput: true}
+
+			err := task.ResolveCollision(test.policy, outputs)
+			if got, want := err != nil, test.expErr; got != want {
+				t.Errorf("error mismatch: got=%v want error=%t", err, want)
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got, want := task.OutputFile, filepath.Join(dir, test.expOutput); got != want {
+				t.Errorf("output mismatch: got=%q want=%q", got, want)
+			}
+
+			if task.Skip != test.expSkip || task.Overwrite != test.expOverwrite || task.Renamed != test.expRenamed {
+				t.Errorf("flags mismatch: got skip=%t overwrite=%t renamed=%t want skip=%t overwrite=%t renamed=%t",
+					task.Skip, task.Overwrite, task.Renamed, test.expSkip, test.expOverwrite, test.expRenamed)
+			}
+
+			if got, want := task.RemoveInput, !test.expSkip; got != want {
+				t.Errorf("remove input mismatch: got=%t want=%t", got, want)
+			}
+		})
+	}
+}
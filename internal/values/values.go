@@ -1,7 +1,7 @@
 package values
 
 const (
-	Version   = 0
+	Version   = 1
 	AppName   = "fenc"
 	Extension = ".fenc"
 	Signature = "fENC"
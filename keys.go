@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/cipher"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/marko-gacesa/fenc/internal/agent"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/secure"
+)
+
+// defaultKeyPhraseEnv is the environment variable with the key phrase used when no key option is provided.
+const defaultKeyPhraseEnv = "FENC_KEY_PHRASE"
+
+const keyURIUsage = "Key provider: prompt, blank, env:VAR, file:PATH, fd:N, cmd:COMMAND, agent[:NAME], master:PATH,\n" +
+	"shares:PATH,... or NAME:ARG for the external provider " + keyprovider.ExternalPrefix + "NAME."
+
+// keyOptions holds the options of a command that select the key provider.
+type keyOptions struct {
+	env string
+	uri string
+
+	noPrompt bool // the user isn't asked for the key phrase by default, like in the git filter
+}
+
+// keyFlags defines the options -P and -key in the flag set.
+func keyFlags(fs *flag.FlagSet) *keyOptions {
+	o := &keyOptions{}
+	fs.StringVar(&o.env, "P", "", "Use key phrase from the provided environment variable.")
+	fs.StringVar(&o.uri, "key", "", keyURIUsage)
+
+	return o
+}
+
+// provider returns the key provider selected by the options, or the default one.
+// If a new key phrase is requested, the user is asked for it, because the environment variable
+// and the agent hold the current one.
+func (o *keyOptions) provider(newKey bool) (keyprovider.Provider, error) {
+	switch {
+	case o.env != "" && o.uri != "":
+		return nil, errors.New("can't use both, the key phrase environment variable and the key provider")
+	case o.env != "":
+		return keyprovider.Env{Name: o.env}, nil
+	case o.uri != "":
+		return keyprovider.FromURI(o.uri)
+	case newKey:
+		return keyprovider.Prompt{}, nil
+	case o.noPrompt:
+		return keyprovider.Chain{keyprovider.Env{Name: defaultKeyPhraseEnv}, agentKeyProvider{}}, nil
+	default:
+		return defaultKeyProvider(), nil
+	}
+}
+
+// defaultKeyProvider returns the key provider used by all commands when no key option is provided:
+// the key phrase from the environment variable FENC_KEY_PHRASE, if it's set, then the key of the agent,
+// if it has one, and finally the user is asked for the key phrase.
+func defaultKeyProvider() keyprovider.Provider {
+	return keyprovider.Chain{keyprovider.Env{Name: defaultKeyPhraseEnv}, agentKeyProvider{}, keyprovider.Prompt{}}
+}
+
+// readKeyBlock gets the key phrase from the provider selected by the options and creates the cipher block.
+func readKeyBlock(o *keyOptions, req keyprovider.Request) (cipher.Block, error) {
+	p, err := o.provider(req.Confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	return providerBlock(p, req)
+}
+
+func providerBlock(p keyprovider.Provider, req keyprovider.Request) (cipher.Block, error) {
+	key, err := p.KeyPhrase(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer secure.Wipe(key)
+
+	return newKeyBlock(key)
+}
+
+// newKeyBlock creates the cipher block from the key phrase. It warns once if a file can be decrypted
+// only with the key phrase normalized to Unicode NFC.
+func newKeyBlock(key []byte) (cipher.Block, error) {
+	block, err := processor.CipherBlock(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher block: %w", err)
+	}
+
+	if pb, ok := block.(*processor.PhraseBlock); ok {
+		var once sync.Once
+		pb.OnFallback = func() {
+			once.Do(func() {
+				log.Println("Warning: Some files were encrypted before key phrases were normalized, with the key phrase")
+				log.Println("composed differently, and only the normalized key phrase decrypts them. Use rekey to update them.")
+			})
+		}
+	}
+
+	return block, nil
+}
+
+// agentKeyProvider is the agent provider used by default. Unlike keyprovider.Agent, it warns
+// if the agent is running, but can't provide the key, for example because it's locked.
+type agentKeyProvider struct{}
+
+func (agentKeyProvider) KeyPhrase(keyprovider.Request) ([]byte, error) {
+	socket := os.Getenv(agent.EnvSocket)
+	if socket == "" {
+		return nil, keyprovider.ErrorUnavailable
+	}
+
+	key, err := agent.Get(socket, agent.DefaultKeyName)
+	if errors.Is(err, agent.ErrorNotFound) {
+		return nil, keyprovider.ErrorUnavailable
+	} else if err != nil {
+		log.Printf("Warning: Key from agent not available: %s", err.Error())
+		return nil, keyprovider.ErrorUnavailable
+	}
+
+	return key, nil
+}
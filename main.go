@@ -2,197 +2,543 @@ package main
 
 import (
 	"crypto/cipher"
+	"crypto/ed25519"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/marko-gacesa/fenc/internal/compress"
 	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/filter"
 	"github.com/marko-gacesa/fenc/internal/hashgen"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/padding"
 	"github.com/marko-gacesa/fenc/internal/password"
 	"github.com/marko-gacesa/fenc/internal/printer"
 	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/secure"
+	"github.com/marko-gacesa/fenc/internal/signing"
 	"github.com/marko-gacesa/fenc/internal/task"
 	"github.com/marko-gacesa/fenc/internal/values"
 )
 
 const version = "1.0.0"
 
-const defaultKeyPhraseEnv = "FENC_KEY_PHRASE"
+const (
+	exitCodeOK          = 0
+	exitCodeSomeFailed  = 1
+	exitCodeAllFailed   = 2
+	exitCodeNothingToDo = 3
+)
+
+// exit codes of the grep command, the same as of the grep utility
+const (
+	exitCodeNoMatch = 1 // no line matched
+	exitCodeTrouble = 2 // a file failed
+)
+
+// exitCodeDiffer is the exit code of the diff command when the files differ, the same as of the diff utility.
+const exitCodeDiffer = 1
 
 func main() {
 	log.SetFlags(0)
 
+	if err := secure.DisableCoreDumps(); err != nil {
+		log.Printf("Warning: %s", err.Error())
+	}
+
 	// Phase: App configuration
 
 	options := struct {
 		hashFn       string
+		strict       bool
+		compression  string
+		padding      string
+		detMode      bool
+		armor        bool
+		signKey      string
+		signerKey    string
+		modeEnc      bool
+		modeDec      bool
+		fileList     string
+		fileListNull bool
+		include      stringList
+		exclude      stringList
+		excludeFrom  stringList
 		outStd       bool
+		outFile      string
+		outDir       string
+		suffix       string
+		stripSuffix  string
 		outNoColor   bool
 		outQuiet     bool
 		filesKeep    bool
+		collision    string
+		force        bool
+		skipExisting bool
+		keepGoing    bool
 		keyUseEmpty  bool
-		keyRaw       string
+		keyRaw       secretValue
 		keyEnv       string
+		keyURI       string
 		keyAllowWeak bool
+		minStrength  int
 		keyNoWarn    bool
 		showVersion  bool
 		showHelp     bool
+		profile      string
 	}{}
 
-	flag.StringVar(&options.hashFn, "s", "sha256", "Hash function (for encryption only). Can be sha256, sha512, md5 or sha1.")
+	flag.BoolVar(&options.modeEnc, "e", false, "Encrypt all input files, even if they already look encrypted.")
+	flag.BoolVar(&options.modeDec, "d", false, "Decrypt all input files.")
+	flag.StringVar(&options.fileList, "T", "", "Read names of input files from the provided file, one per line. Use - to read them from stdin.")
+	flag.BoolVar(&options.fileListNull, "null", false, "Names of input files read with -T are separated by NUL characters, as produced by find -print0.")
+	flag.Var(&options.include, "include", "Process only files matching the provided pattern. Can be repeated.")
+	flag.Var(&options.exclude, "exclude", "Don't process files matching the provided pattern, in .gitignore format. Can be repeated.")
+	flag.Var(&options.excludeFrom, "exclude-from", "Read exclude patterns from the provided file, like .fencignore. Can be repeated.")
+	flag.StringVar(&options.hashFn, "s", "sha256", "Hash function (for encryption only). Can be sha256, sha512, md5 or sha1. The md5 and sha1 are deprecated.")
+	flag.BoolVar(&options.strict, "strict", false, "Refuse to encrypt with deprecated hash functions (md5 and sha1).")
+	flag.StringVar(&options.compression, "compress", "auto", "Compression (for encryption only). Can be none, gzip, gzip:1 to gzip:9, zstd or auto.\nThe auto compression skips data that is already compressed and uses gzip for the rest.")
+	flag.StringVar(&options.padding, "pad", "none", "Length-hiding padding (for encryption only). Can be none, padme, bucket or block:N, like block:64K.\nThe padme padding adds at most 12%, the bucket padding rounds sizes up to a power of two.")
+	flag.BoolVar(&options.detMode, "deterministic", false, "Deterministic mode (for encryption only): equal files give equal encrypted files, useful for deduplication.\nIt reveals which files are equal.")
+	flag.BoolVar(&options.armor, "armor", false, "Write encrypted files as armored text, which can be pasted into tickets, chat or config files (for encryption only).\nArmored files are detected and decrypted automatically.")
+	flag.StringVar(&options.signKey, "sign", "", "Embed the signature made with the provided private key (for encryption only).")
+	flag.StringVar(&options.signerKey, "signer", "", "Require the signature made with the private key matching the provided public key (for decryption only).")
 	flag.BoolVar(&options.outStd, "o", false, "Output to stdout (for decryption only). Don't create output files.")
+	flag.StringVar(&options.outFile, "O", "", "Write the output to the provided file. Only if there is a single input file.")
+	flag.StringVar(&options.outDir, "out-dir", "", "Write output files to the provided directory, keeping the relative directory structure.")
+	flag.StringVar(&options.suffix, "suffix", values.Extension, "Extension added to encrypted files.")
+	flag.StringVar(&options.stripSuffix, "strip-suffix", values.Extension, "Extension removed from decrypted files. Defaults to the value of -suffix.")
 	flag.BoolVar(&options.outNoColor, "c", false, "Disable color output.")
 	flag.BoolVar(&options.outQuiet, "q", false, "Suppress progress output. It's always suppressed if output is stdout.")
 	flag.BoolVar(&options.filesKeep, "k", false, "Keep source files. Only if output is not stdout.")
+	flag.StringVar(&options.collision, "collision", "fail", "What to do if an output file already exists. Can be fail, skip, overwrite or rename.")
+	flag.BoolVar(&options.force, "force", false, "Overwrite existing output files. Same as -collision=overwrite.")
+	flag.BoolVar(&options.skipExisting, "skip-existing", false, "Skip files whose output files already exist. Same as -collision=skip.")
+	flag.BoolVar(&options.keepGoing, "keep-going", false, "Process all valid input files even if some of them can't be processed.")
 	flag.BoolVar(&options.keyUseEmpty, "b", false, "Insecure. Don't prompt for the key phrase. Use blank key phrase.")
-	flag.StringVar(&options.keyRaw, "p", "", "Use the provided value as the key phrase.")
+	flag.Var(&options.keyRaw, "p", "Use the provided value as the key phrase.")
 	flag.StringVar(&options.keyEnv, "P", "", "Use key phrase from the provided environment variable.")
-	flag.BoolVar(&options.keyAllowWeak, "u", false, "Insecure. Allow weak or empty passwords. Assume UTF-8 encoding for keys.")
+	flag.StringVar(&options.keyURI, "key", "", keyURIUsage)
+	flag.BoolVar(&options.keyAllowWeak, "u", false, "Insecure. Allow weak or empty passwords.")
+	flag.IntVar(&options.minStrength, "min-strength", password.DefaultMinScore, "Minimum strength score of the key phrase, from 0 to 4 (for encryption only).\nThe score is estimated from the patterns in the key phrase, see the genpass command.")
 	flag.BoolVar(&options.keyNoWarn, "w", false, "Don't warn against empty key phrase.")
 	flag.BoolVar(&options.showVersion, "v", false, "Display version and exit.")
 	flag.BoolVar(&options.showHelp, "h", false, "Display usage information and exit.")
+	flag.StringVar(&options.profile, "profile", "", "Use the named profile from the config file. Options in the command line override it.")
+
+	// Commands are dispatched after the options of the main command are defined,
+	// because the config command needs them.
+	if len(os.Args) > 1 {
+		if cmd, ok := commandFromArg(os.Args[1]); ok {
+			cmd.run(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	fileNameList := flag.Args()
 
+	flagsSet := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		flagsSet[f.Name] = true
+	})
+
+	// the version and the usage don't depend on the config file, so they are shown even if it's invalid
 	if options.showVersion {
 		fmt.Println(version)
 		return
 	}
 
-	if len(fileNameList) == 0 || options.showHelp {
-		fmt.Println("Encrypts/decrypts files. Source files will be removed unless the -k option is used.")
-		fmt.Println("Newly encrypted files get the '.fenc' extension. Decrypted files lose the '.fenc' extension.")
-		fmt.Println()
-		fmt.Printf("Usage: %s <options> <file_list>\n", values.AppName)
-		fmt.Println()
-		fmt.Println("Options:")
-		flag.PrintDefaults()
+	if options.showHelp {
+		printUsage()
+		return
+	}
+
+	// Phase: Apply config file
+
+	if err := applyConfig(flag.CommandLine, options.profile, flagsSet); err != nil {
+		fatalf("Config error: %s", err.Error())
+	}
+
+	if options.fileList != "" {
+		separator := byte('\n')
+		if options.fileListNull {
+			separator = 0
+		}
+
+		list, err := file.ReadListFile(options.fileList, separator)
+		if err != nil {
+			fatalf("File list error: %s", err.Error())
+		}
+
+		if len(list) == 0 && len(fileNameList) == 0 {
+			log.Println("No input files.")
+			os.Exit(exitCodeNothingToDo)
+		}
+
+		fileNameList = append(fileNameList, list...)
+	}
+
+	if len(fileNameList) == 0 {
+		printUsage()
 		return
 	}
 
 	// Phase: Validate and sanitize options
 
 	err := func() error {
+		if options.modeEnc && options.modeDec {
+			return errors.New("can't use both, the encrypt mode and the decrypt mode")
+		}
+
 		if options.outStd {
 			options.outQuiet = true
 		}
 
-		if options.keyEnv != "" {
-			if options.keyRaw != "" {
-				return errors.New("can't use both, the key phrase environment variable and the raw key phrase")
+		if options.outFile != "" {
+			if options.outStd {
+				return errors.New("can't use both, the output file and the output to stdout")
 			}
 
-			if options.keyUseEmpty {
-				return errors.New("can't use both, the key phrase environment variable and request empty key phrase")
+			if options.outDir != "" {
+				return errors.New("can't use both, the output file and the output directory")
 			}
 
-			if keyRaw, ok := os.LookupEnv(options.keyEnv); !ok || keyRaw == "" {
-				return errors.New("environment variable for key phrase is not defined or has no value")
-			} else {
-				options.keyRaw = keyRaw
+			if len(fileNameList) != 1 {
+				return errors.New("the output file can be used only with a single input file")
 			}
-		} else if options.keyRaw != "" {
-			if options.keyUseEmpty {
-				return errors.New("can't use both, the raw key phrase and request empty key phrase")
+		}
+
+		if options.suffix == "" && options.outDir == "" && options.outFile == "" {
+			return errors.New("the extension for encrypted files can't be empty, unless an output directory is used")
+		}
+
+		if options.force || options.skipExisting {
+			if options.force && options.skipExisting {
+				return errors.New("can't use both, force overwrite and skip existing")
 			}
-		} else if !options.keyUseEmpty {
-			if keyRaw, ok := os.LookupEnv(defaultKeyPhraseEnv); ok {
-				options.keyRaw = keyRaw
+
+			if flagsSet["collision"] {
+				return errors.New("can't use the collision policy together with force overwrite or skip existing")
 			}
+
+			if options.force {
+				options.collision = "overwrite"
+			} else {
+				options.collision = "skip"
+			}
+		}
+
+		if !flagsSet["strip-suffix"] {
+			options.stripSuffix = options.suffix
+		}
+
+		if options.minStrength < 0 || options.minStrength > password.MaxScore {
+			return fmt.Errorf("the minimum strength score must be from 0 to %d", password.MaxScore)
+		}
+
+		keySources := 0
+		for _, set := range []bool{options.keyUseEmpty, len(options.keyRaw) > 0, options.keyEnv != "", options.keyURI != ""} {
+			if set {
+				keySources++
+			}
+		}
+
+		if keySources > 1 {
+			return errors.New("can use only one of blank key phrase, raw key phrase, key phrase environment variable and key provider")
 		}
 
 		return nil
 	}()
 	if err != nil {
-		log.Fatalf("Options error: %s", err.Error())
-		return
+		fatalf("Options error: %s", err.Error())
+	}
+
+	// Phase: Select key provider
+
+	keyProvider, err := func() (keyprovider.Provider, error) {
+		switch {
+		case options.keyUseEmpty:
+			return keyprovider.Blank{}, nil
+		case len(options.keyRaw) > 0:
+			return keyprovider.Literal(options.keyRaw), nil
+		case options.keyEnv != "":
+			return keyprovider.Env{Name: options.keyEnv}, nil
+		case options.keyURI != "":
+			return keyprovider.FromURI(options.keyURI)
+		default:
+			return defaultKeyProvider(), nil
+		}
+	}()
+	if err != nil {
+		fatalf("Key provider error: %s", err.Error())
 	}
 
 	// Phase: Create hash generator
 
 	hg, err := hashgen.FromName(options.hashFn)
 	if err != nil {
-		log.Fatalf("Hash function error: %s", err.Error())
-		return
+		fatalf("Hash function error: %s", err.Error())
 	}
 
-	// Phase: Prepare list of tasks
+	// Phase: Create compression method
+
+	comp, err := compress.FromName(options.compression)
+	if err != nil {
+		fatalf("Compression error: %s", err.Error())
+	}
 
-	tasks, needEncryptor, needDecryptor, err := func() (tasks []task.Task, needEncryptor, needDecryptor bool, err error) {
-		tasks = make([]task.Task, len(fileNameList))
-		for i, fileName := range fileNameList {
-			var t task.Task
-			t.InputFile = fileName
+	// Phase: Create padding method
 
-			if err = file.MustBeReadable(t.InputFile); err != nil {
-				return
+	pad, err := padding.FromName(options.padding)
+	if err != nil {
+		fatalf("Padding error: %s", err.Error())
+	}
+
+	// Phase: Read signing keys
+
+	var (
+		signer   ed25519.PrivateKey
+		verifier ed25519.PublicKey
+	)
+
+	if options.signKey != "" {
+		signer, err = signing.ReadPrivateKey(options.signKey)
+		if err != nil {
+			fatalf("Signing key error: %s", err.Error())
+		}
+	}
+
+	if options.signerKey != "" {
+		verifier, err = signing.ReadPublicKey(options.signerKey)
+		if err != nil {
+			fatalf("Signer key error: %s", err.Error())
+		}
+	}
+
+	encryptOpts := processor.EncryptOptions{
+		HashID:        hg.ID,
+		Compression:   comp,
+		Padding:       pad,
+		Signer:        signer,
+		WrapKey:       true,
+		Deterministic: options.detMode,
+		Armor:         options.armor,
+	}
+
+	decryptOpts := processor.DecryptOptions{
+		Signer: verifier,
+	}
+
+	// Phase: Filter input files
+
+	fileNameList, err = func() ([]string, error) {
+		var f filter.Filter
+
+		for _, pattern := range options.include {
+			if err := f.Include(pattern); err != nil {
+				return nil, err
 			}
+		}
 
-			if isEncrypted := strings.HasSuffix(fileName, values.Extension); isEncrypted {
-				needDecryptor = true
-				t.ProcEnc = false
-				t.OutputFile = strings.TrimSuffix(fileName, values.Extension)
-			} else {
-				needEncryptor = true
-				t.ProcEnc = true
-				t.OutputFile = fileName + values.Extension
+		for _, pattern := range options.exclude {
+			if err := f.Exclude(pattern); err != nil {
+				return nil, err
 			}
+		}
 
-			// output to stdout only if explicitly asked and never for encryptor output
-			t.ToStdout = options.outStd && !t.ProcEnc
-			// keeping the input file only if explicitly asked and not if writing to stdout
-			t.RemoveInput = !options.filesKeep && !t.ToStdout
+		for _, fileName := range options.excludeFrom {
+			if err := f.ExcludeFrom(fileName); err != nil {
+				return nil, err
+			}
+		}
 
-			if !t.ToStdout {
-				if err = file.MustNotExist(t.OutputFile); err != nil {
+		if f.Empty() {
+			return fileNameList, nil
+		}
+
+		return slices.DeleteFunc(fileNameList, func(fileName string) bool {
+			return !f.Match(fileName)
+		}), nil
+	}()
+	if err != nil {
+		fatalf("Filter error: %s", err.Error())
+	}
+
+	if len(fileNameList) == 0 {
+		log.Println("No input files left after filtering.")
+		os.Exit(exitCodeNothingToDo)
+	}
+
+	// Phase: Create collision policy
+
+	collision, err := task.CollisionPolicyFromName(options.collision)
+	if err != nil {
+		fatalf("Collision policy error: %s", err.Error())
+	}
+
+	// Phase: Prepare list of tasks
+
+	tasks, preflightErrs, needEncryptor, needDecryptor := func() (tasks []task.Task, errs []error, needEncryptor, needDecryptor bool) {
+		tasks = make([]task.Task, 0, len(fileNameList))
+		outputs := make(map[string]string, len(fileNameList))
+		for _, fileName := range fileNameList {
+			t, err := func() (t task.Task, err error) {
+				t.InputFile = fileName
+
+				if err = file.MustBeReadable(t.InputFile); err != nil {
+					return
+				}
+
+				switch {
+				case options.modeEnc:
+					t.ProcEnc = true
+				case options.modeDec:
+					t.ProcEnc = false
+				default:
+					var (
+						version     uint16
+						isEncrypted bool
+					)
+
+					version, isEncrypted, err = processor.ProbeFile(t.InputFile)
+					if err != nil {
+						return
+					}
+
+					if isEncrypted && version > values.Version {
+						err = fmt.Errorf("unsupported format version %d: %q", version, t.InputFile)
+						return
+					}
+
+					t.ProcEnc = !isEncrypted
+				}
+
+				// output to stdout only if explicitly asked and never for encryptor output
+				t.ToStdout = options.outStd && !t.ProcEnc
+
+				switch {
+				case t.ToStdout:
+				case options.outFile != "":
+					t.OutputFile = options.outFile
+				case t.ProcEnc:
+					t.OutputFile = fileName + options.suffix
+				case strings.HasSuffix(fileName, options.stripSuffix):
+					t.OutputFile = strings.TrimSuffix(fileName, options.stripSuffix)
+				default:
+					err = fmt.Errorf("can't name the output, encrypted file has no %q extension: %q", options.stripSuffix, fileName)
+					return
+				}
+
+				if options.outDir != "" && !t.ToStdout {
+					t.OutputFile = file.Relocate(t.OutputFile, options.outDir)
+				}
+
+				if !t.ToStdout && file.Same(t.InputFile, t.OutputFile) {
+					err = fmt.Errorf("output file is the same as the input file: %q", fileName)
 					return
 				}
+
+				// keeping the input file only if explicitly asked and not if writing to stdout
+				t.RemoveInput = !options.filesKeep && !t.ToStdout
+
+				if !t.ToStdout {
+					if err = t.ResolveCollision(collision, outputs); err != nil {
+						return
+					}
+				}
+
+				return
+			}()
+			if err != nil {
+				errs = append(errs, err)
+				continue
 			}
 
-			tasks[i] = t
+			if !t.ToStdout && !t.Skip {
+				outputs[filepath.Clean(t.OutputFile)] = t.InputFile
+			}
+
+			if t.Skip {
+				// nothing to do
+			} else if t.ProcEnc {
+				needEncryptor = true
+			} else {
+				needDecryptor = true
+			}
+
+			tasks = append(tasks, t)
 		}
 
 		return
 	}()
-	if err != nil {
-		log.Fatalf("Input file error: %s", err.Error())
-		return
+	if len(preflightErrs) > 0 {
+		for _, err := range preflightErrs {
+			log.Printf("Input file error: %s", err.Error())
+		}
+
+		if !options.keepGoing {
+			os.Exit(exitCodeAllFailed)
+		}
+
+		if len(tasks) == 0 {
+			log.Println("No valid input files.")
+			os.Exit(exitCodeAllFailed)
+		}
 	}
 
-	_ = needEncryptor
 	_ = needDecryptor
 
+	// Phase: Check encryption settings
+
+	if needEncryptor && hg.Weak() {
+		if options.strict {
+			fatalf("Hash function error: %s is deprecated and refused in strict mode", hg.Name)
+		}
+
+		log.Printf("Warning: Hash function %s is deprecated. Use sha256 or sha512.", hg.Name)
+	}
+
+	if needEncryptor && options.detMode {
+		log.Println("Warning: Deterministic mode reveals which encrypted files have equal content.")
+	}
+
 	// Phase: Ask for password and create cipher block
 
 	block, err := func() (block cipher.Block, err error) {
-		var key []byte
+		if !needEncryptor && !needDecryptor {
+			return nil, nil // all tasks are skipped
+		}
 
-		if !options.keyUseEmpty {
-			key, err = password.Input(needEncryptor && !options.keyAllowWeak, needEncryptor && options.keyRaw == "")
-			if err != nil {
-				return nil, err
-			}
+		key, err := keyProvider.KeyPhrase(keyprovider.Request{
+			Prompt:   "Enter key phrase",
+			Confirm:  needEncryptor,
+			Strength: needEncryptor && !options.keyAllowWeak,
+			MinScore: options.minStrength,
+		})
+		secure.Wipe(options.keyRaw)
+		if err != nil {
+			return nil, err
 		}
 
+		defer secure.Wipe(key)
+
 		if len(key) == 0 && !options.keyNoWarn && needEncryptor {
 			log.Println("Warning: Using empty key phrase.")
 		}
 
-		block, err = processor.CipherBlock(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create cipher block: %w", err)
-		}
-
-		return block, nil
+		return newKeyBlock(key)
 	}()
 	if err != nil {
-		log.Fatalf("Key phrase error: %s", err.Error())
-		return
+		fatalf("Key phrase error: %s", err.Error())
 	}
 
 	// Phase: Process each input file
@@ -214,28 +560,64 @@ func main() {
 	}()
 
 	var (
-		countDone int
-		countFail int
+		countDone    int
+		countFail    int
+		countSkip    int
+		countRenamed int
 	)
 
 	for _, t := range tasks {
 		p.PrintTask(&t)
 
+		if t.Skip {
+			countSkip++
+			p.PrintSkip()
+			p.PrintLn()
+			continue
+		}
+
+		// an existing output file is replaced only after the new one is fully written
+		outputFile := t.OutputFile
+		if t.Overwrite {
+			outputFile, err = file.TempName(t.OutputFile)
+			if err != nil {
+				p.PrintFail()
+				p.PrintError(err, "Failed to prepare output")
+				countFail++
+				p.PrintLn()
+				continue
+			}
+		}
+
+		if !t.ToStdout {
+			err = os.MkdirAll(filepath.Dir(t.OutputFile), 0o755)
+			if err != nil {
+				p.PrintFail()
+				p.PrintError(err, "Failed to create output directory")
+				countFail++
+				p.PrintLn()
+				continue
+			}
+		}
+
 		if t.ProcEnc {
-			err = processor.EncryptFile(hg.ID, block, t.InputFile, t.OutputFile)
+			err = processor.EncryptFile(encryptOpts, block, t.InputFile, outputFile)
 		} else if t.ToStdout {
-			err = processor.DecryptToStdOut(block, t.InputFile)
+			err = processor.DecryptToStdOut(decryptOpts, block, t.InputFile)
 		} else {
-			err = processor.DecryptToFile(block, t.InputFile, t.OutputFile)
+			err = processor.DecryptToFile(decryptOpts, block, t.InputFile, outputFile)
+		}
+		if err == nil && t.Overwrite {
+			err = os.Rename(outputFile, t.OutputFile)
 		}
 		if err != nil {
 			p.PrintFail()
 			p.PrintError(err, "Failed to process")
 
 			if !t.ToStdout {
-				err = os.Remove(t.OutputFile)
+				err = os.Remove(outputFile)
 				if err != nil && !os.IsNotExist(err) {
-					p.PrintError(err, "Failed to delete failed output %s", t.OutputFile)
+					p.PrintError(err, "Failed to delete failed output %s", outputFile)
 				}
 			}
 
@@ -248,6 +630,11 @@ func main() {
 		countDone++
 		p.PrintDone()
 
+		if t.Renamed {
+			countRenamed++
+			p.PrintRenamed()
+		}
+
 		if t.RemoveInput {
 			err = os.Remove(t.InputFile)
 			if err != nil {
@@ -258,15 +645,67 @@ func main() {
 		p.PrintLn()
 	}
 
-	var exitCode int
+	countFail += len(preflightErrs)
 
-	if countFail > 0 {
-		exitCode++
-	}
+	p.PrintSummary(countDone, countFail, countSkip, countRenamed)
 
-	if countDone == 0 {
-		exitCode++
-	}
+	exitWithCounts(countDone, countFail)
+}
+
+// printUsage prints the usage information of the main command and the list of commands.
+func printUsage() {
+	fmt.Println("Encrypts/decrypts files. Source files will be removed unless the -k option is used.")
+	fmt.Println("Files that start with the fenc signature, or are armored, are decrypted, all other files are encrypted.")
+	fmt.Println("Use -e or -d to force the direction.")
+	fmt.Println("Newly encrypted files get the '.fenc' extension. Decrypted files lose the '.fenc' extension.")
+	fmt.Println("Output files are written next to the input files, unless -out-dir or -O is used.")
+	fmt.Printf("Default options and named profiles can be set in the config file, see '%s config show'.\n", values.AppName)
+	fmt.Println()
+	fmt.Println("Exit codes: 0 if all files are processed, 1 if some files failed, 2 if all files failed")
+	fmt.Println("or nothing could be started, 3 if there was nothing to do.")
+	fmt.Println()
+	fmt.Printf("Usage: %s <options> <file_list>\n", values.AppName)
+	fmt.Printf("       %s <options> -T <file_with_file_list>\n", values.AppName)
+	fmt.Println()
+	fmt.Println("If the first argument is the name of a command, the command is run, even if a file with that name exists.")
+	fmt.Printf("Use ./ or -- to process such a file, -- ends the options and the following arguments are files: %s -- cat\n", values.AppName)
+	fmt.Println()
+	fmt.Println("Options:")
+	flag.PrintDefaults()
+	fmt.Println()
+	printCommands()
+}
+
+// fatalf prints the error message and exits.
+// Nothing has been processed, so it uses the same exit code as if all files failed.
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitCodeAllFailed)
+}
+
+// stringList is a flag value that collects all values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// secretValue is a flag value that keeps key material in locked memory and never shows it in the usage.
+// The command line argument itself is a string that can't be wiped, so it's better to avoid such flags.
+type secretValue []byte
+
+func (v *secretValue) String() string {
+	return ""
+}
+
+func (v *secretValue) Set(value string) error {
+	secure.Wipe(*v)
+	*v = secure.LockedString(value)
 
-	os.Exit(exitCode)
+	return nil
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/password"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/signing"
+)
+
+func runRekey(args []string) {
+	cmd, _ := findCommand("rekey")
+	fs := newFlagSet(cmd)
+	var oldKeys, newKeys keyOptions
+	fs.StringVar(&oldKeys.env, "old-env", "", "Use the current key phrase from the provided environment variable.")
+	fs.StringVar(&oldKeys.uri, "old-key", "", "Provider of the current key phrase. See -key in the main usage.")
+	fs.StringVar(&newKeys.env, "new-env", "", "Use the new key phrase from the provided environment variable.")
+	fs.StringVar(&newKeys.uri, "new-key", "", "Provider of the new key phrase. See -key in the main usage.")
+	allowWeak := fs.Bool("u", false, "Insecure. Allow weak or empty new key phrase.")
+	minStrength := fs.Int("min-strength", password.DefaultMinScore, "Minimum strength score of the new key phrase, from 0 to 4.")
+	signKey := fs.String("sign", "", "Embed a new signature made with the provided private key. Without it, signatures are removed.")
+	padName := fs.String("pad", "none", "Length-hiding padding, used only for files that must be encrypted again. See the main usage.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	pad, err := padding.FromName(*padName)
+	if err != nil {
+		fatalf("Padding error: %s", err.Error())
+	}
+
+	var signer ed25519.PrivateKey
+	if *signKey != "" {
+		signer, err = signing.ReadPrivateKey(*signKey)
+		if err != nil {
+			fatalf("Signing key error: %s", err.Error())
+		}
+	}
+
+	for _, fileName := range fs.Args() {
+		if err = file.MustBeReadable(fileName); err != nil {
+			fatalf("Input file error: %s", err.Error())
+		}
+	}
+
+	oldBlock, err := readKeyBlock(&oldKeys, keyprovider.Request{Prompt: "Enter current key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	newBlock, err := readKeyBlock(&newKeys, keyprovider.Request{
+		Prompt:   "Enter new key phrase",
+		Confirm:  true,
+		Strength: !*allowWeak,
+		MinScore: *minStrength,
+	})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	opts := processor.EncryptOptions{
+		Padding: pad,
+		Signer:  signer,
+	}
+
+	var verifyOpts processor.DecryptOptions
+	if signer != nil {
+		verifyOpts.Signer = signer.Public().(ed25519.PublicKey)
+	}
+
+	var countDone, countFail int
+
+	for _, fileName := range fs.Args() {
+		reencrypted, err := rekeyFile(opts, verifyOpts, oldBlock, newBlock, fileName)
+		if err != nil {
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		if reencrypted {
+			fmt.Printf("%s: DONE (encrypted again)\n", fileName)
+		} else {
+			fmt.Printf("%s: DONE (header changed)\n", fileName)
+		}
+
+		countDone++
+	}
+
+	exitWithCounts(countDone, countFail)
+}
+
+// rekeyFile writes the file with the new key to a temporary file, verifies that
+// it can be decrypted with the new key and only then replaces the original.
+func rekeyFile(opts processor.EncryptOptions, verifyOpts processor.DecryptOptions, oldBlock, newBlock cipher.Block, fileName string) (reencrypted bool, err error) {
+	err = file.Replace(fileName, func(tempName string) error {
+		reencrypted, err = processor.RekeyFile(opts, oldBlock, newBlock, fileName, tempName)
+		if err != nil {
+			return err
+		}
+
+		if err = processor.VerifyFile(verifyOpts, newBlock, tempName); err != nil {
+			return fmt.Errorf("verification failed: %w", err)
+		}
+
+		return nil
+	})
+
+	return
+}
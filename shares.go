@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/agent"
+	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/masterkey"
+	"github.com/marko-gacesa/fenc/internal/secure"
+	"github.com/marko-gacesa/fenc/internal/shamir"
+)
+
+const shareExtension = ".share"
+
+func runSplit(args []string) {
+	cmd, _ := findCommand("split")
+	fs := newFlagSet(cmd)
+	shareCount := fs.Int("shares", 5, "Number of recovery shares.")
+	threshold := fs.Int("threshold", 3, "Number of recovery shares needed to rebuild the master key.")
+	inFile := fs.String("in", "", "Split the existing master key from the provided file, instead of generating a new one.")
+	outFile := fs.String("o", "", "Also write the generated master key to the provided file. It decrypts everything without the shares.")
+	text := fs.Bool("text", false, "Print the recovery shares to stdout, for printing on paper, instead of writing share files.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || (*inFile != "" && *outFile != "") {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	if *threshold < 2 || *shareCount < *threshold || *shareCount > shamir.MaxShares {
+		fatalf("Options error: the threshold must be at least 2 and at most the number of shares, which is at most %d", shamir.MaxShares)
+	}
+
+	name := fs.Arg(0)
+
+	shareFiles := make([]string, *shareCount)
+	for i := range shareFiles {
+		shareFiles[i] = fmt.Sprintf("%s-%d%s", name, i+1, shareExtension)
+	}
+
+	var outputs []string
+	if *outFile != "" {
+		outputs = append(outputs, *outFile)
+	}
+	if !*text {
+		outputs = append(outputs, shareFiles...)
+	}
+
+	for _, fileName := range outputs {
+		if err := file.MustNotExist(fileName); err != nil {
+			fatalf("Output file error: %s", err.Error())
+		}
+	}
+
+	var (
+		key []byte
+		err error
+	)
+
+	if *inFile != "" {
+		key, err = masterkey.ReadFile(*inFile)
+	} else {
+		key, err = masterkey.Generate()
+	}
+	if err != nil {
+		fatalf("Master key error: %s", err.Error())
+	}
+
+	defer secure.Wipe(key)
+
+	shares, err := masterkey.Split(key, *shareCount, *threshold)
+	if err != nil {
+		fatalf("Split error: %s", err.Error())
+	}
+
+	defer masterkey.WipeShares(shares)
+
+	// files written before a failure are removed, so that no incomplete set of shares is left
+	var written []string
+	failf := func(format string, args ...any) {
+		for _, fileName := range written {
+			if err := file.Wipe(fileName); err != nil {
+				log.Printf("Failed to remove %q: %s", fileName, err.Error())
+			}
+		}
+
+		masterkey.WipeShares(shares)
+		secure.Wipe(key)
+
+		fatalf(format, args...)
+	}
+
+	if *outFile != "" {
+		if err = masterkey.WriteFile(*outFile, key); err != nil {
+			failf("Master key error: %s", err.Error())
+		}
+
+		written = append(written, *outFile)
+
+		fmt.Printf("Master key: %s\n", *outFile)
+		log.Printf("Warning: the master key file %q decrypts all files without the shares. Keep it safe or remove it.", *outFile)
+	}
+
+	for i, share := range shares {
+		comment := masterkey.ShareComment(i+1, *shareCount, *threshold) + "\n"
+
+		if *text {
+			fmt.Print(comment)
+			_, _ = os.Stdout.Write(share)
+			fmt.Print("\n\n")
+			continue
+		}
+
+		if err = writeNewFile(shareFiles[i], comment, share); err != nil {
+			failf("Share file error: %s", err.Error())
+		}
+
+		written = append(written, shareFiles[i])
+
+		fmt.Printf("Share %d: %s\n", i+1, shareFiles[i])
+	}
+}
+
+func runCombine(args []string) {
+	cmd, _ := findCommand("combine")
+	fs := newFlagSet(cmd)
+	outFile := fs.String("o", "", "Write the rebuilt master key to the provided file.")
+	toAgent := fs.Bool("agent", false, "Add the rebuilt master key to the running agent, instead of writing it to a file.")
+	ttl := fs.Duration("ttl", 0, "How long the agent keeps the master key. Defaults to the agent's setting.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || (*outFile == "") == !*toAgent {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	if *outFile != "" {
+		if err := file.MustNotExist(*outFile); err != nil {
+			fatalf("Output file error: %s", err.Error())
+		}
+	}
+
+	var lines [][]byte
+
+	defer func() { masterkey.WipeShares(lines) }()
+
+	for _, fileName := range fs.Args() {
+		var (
+			fileLines [][]byte
+			err       error
+		)
+
+		if fileName == "-" {
+			fileLines, err = masterkey.ReadShares(os.Stdin)
+		} else {
+			fileLines, err = masterkey.ReadShareFiles([]string{fileName})
+		}
+		if err != nil {
+			fatalf("Share file error: %s", err.Error())
+		}
+
+		lines = append(lines, fileLines...)
+	}
+
+	key, err := masterkey.Combine(lines)
+	if err != nil {
+		fatalf("Combine error: %s", err.Error())
+	}
+
+	defer secure.Wipe(key)
+
+	if *toAgent {
+		socket := os.Getenv(agent.EnvSocket)
+		if socket == "" {
+			fatalf("Agent error: %s is not set", agent.EnvSocket)
+		}
+
+		if err = agent.Add(socket, agent.DefaultKeyName, key, *ttl); err != nil {
+			fatalf("Agent error: %s", err.Error())
+		}
+
+		fmt.Println("Master key added to the agent.")
+		return
+	}
+
+	if err = masterkey.WriteFile(*outFile, key); err != nil {
+		fatalf("Master key error: %s", err.Error())
+	}
+
+	fmt.Printf("Master key: %s\n", *outFile)
+}
+
+// writeNewFile writes the comment and the share, in a line, to a new file, readable only by the owner.
+// The file is removed if it can't be written completely.
+func writeNewFile(fileName, comment string, share []byte) (err error) {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		errClose := f.Close()
+		if errClose != nil && err == nil {
+			err = errClose
+		}
+
+		if err != nil {
+			_ = os.Remove(fileName)
+		}
+	}()
+
+	content := secure.Alloc(len(comment) + len(share) + 1)
+	defer secure.Wipe(content)
+
+	n := copy(content, comment)
+	n += copy(content[n:], share)
+	content[n] = '\n'
+
+	_, err = f.Write(content)
+
+	return
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// terminationSignals is empty on this platform, only os.Interrupt can be handled.
+var terminationSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminationSignals are the signals, besides os.Interrupt, that terminate fenc,
+// so it can clean up before it exits.
+var terminationSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
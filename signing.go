@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/signing"
+)
+
+func runKeygen(args []string) {
+	cmd, _ := findCommand("keygen")
+	fs := newFlagSet(cmd)
+	sign := fs.Bool("sign", false, "Generate an Ed25519 key pair for signing.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	if !*sign {
+		fatalf("Key error: only signing keys can be generated, use -sign")
+	}
+
+	fileName := fs.Arg(0)
+
+	if err := signing.GenerateKey(fileName); err != nil {
+		fatalf("Key error: %s", err.Error())
+	}
+
+	fmt.Printf("Private key: %s\n", fileName)
+	fmt.Printf("Public key:  %s.pub\n", fileName)
+}
+
+func runSign(args []string) {
+	cmd, _ := findCommand("sign")
+	fs := newFlagSet(cmd)
+	keyFile := fs.String("key", "", "Private key file, created with the keygen command.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || *keyFile == "" {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	key, err := signing.ReadPrivateKey(*keyFile)
+	if err != nil {
+		fatalf("Key error: %s", err.Error())
+	}
+
+	var countDone, countFail int
+
+	for _, fileName := range fs.Args() {
+		err = func() error {
+			sigFile := fileName + signing.SignatureExtension
+
+			if err := file.MustNotExist(sigFile); err != nil {
+				return err
+			}
+
+			f, err := os.Open(fileName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			sig, err := signing.SignDetached(key, f)
+			if err != nil {
+				return err
+			}
+
+			return signing.WriteSignature(sigFile, sig)
+		}()
+		if err != nil {
+			log.Printf("Failed to sign %q: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		countDone++
+	}
+
+	exitWithCounts(countDone, countFail)
+}
+
+func runVerify(args []string) {
+	cmd, _ := findCommand("verify")
+	fs := newFlagSet(cmd)
+	signerFile := fs.String("signer", "", "Public key file of the signer.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || *signerFile == "" {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	key, err := signing.ReadPublicKey(*signerFile)
+	if err != nil {
+		fatalf("Key error: %s", err.Error())
+	}
+
+	var countDone, countFail int
+
+	for _, fileName := range fs.Args() {
+		err = func() error {
+			sig, err := signing.ReadSignature(fileName + signing.SignatureExtension)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(fileName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			return signing.VerifyDetached(key, f, sig)
+		}()
+		if err != nil {
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		fmt.Printf("%s: OK\n", fileName)
+		countDone++
+	}
+
+	exitWithCounts(countDone, countFail)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/marko-gacesa/fenc/internal/file"
+	"github.com/marko-gacesa/fenc/internal/hashgen"
+	"github.com/marko-gacesa/fenc/internal/keyprovider"
+	"github.com/marko-gacesa/fenc/internal/padding"
+	"github.com/marko-gacesa/fenc/internal/processor"
+	"github.com/marko-gacesa/fenc/internal/signing"
+)
+
+func runUpgrade(args []string) {
+	cmd, _ := findCommand("upgrade")
+	fs := newFlagSet(cmd)
+	dryRun := fs.Bool("dry-run", false, "Only list the files that need upgrading and why.")
+	hashFn := fs.String("s", "sha256", "Hash function that replaces deprecated ones. Can be sha256 or sha512.")
+	keys := keyFlags(fs)
+	signKey := fs.String("sign", "", "Embed a new signature made with the provided private key. Without it, signatures are removed.")
+	padName := fs.String("pad", "none", "Length-hiding padding. See the main usage.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitCodeAllFailed)
+	}
+
+	hg, err := hashgen.FromName(*hashFn)
+	if err != nil {
+		fatalf("Hash function error: %s", err.Error())
+	}
+
+	if hg.Weak() {
+		fatalf("Hash function error: %s is deprecated", hg.Name)
+	}
+
+	pad, err := padding.FromName(*padName)
+	if err != nil {
+		fatalf("Padding error: %s", err.Error())
+	}
+
+	var signer ed25519.PrivateKey
+	if *signKey != "" {
+		signer, err = signing.ReadPrivateKey(*signKey)
+		if err != nil {
+			fatalf("Signing key error: %s", err.Error())
+		}
+	}
+
+	var countDone, countFail int
+	var outdated []string
+	signed := map[string]bool{}
+
+	for _, fileName := range fs.Args() {
+		h, err := processor.ReadHeaderFile(fileName)
+		if err != nil {
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		reasons := processor.Outdated(h)
+		if reasons == nil {
+			if !*dryRun {
+				fmt.Printf("%s: up to date\n", fileName)
+			}
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("%s: %s\n", fileName, strings.Join(reasons, ", "))
+			countDone++
+			continue
+		}
+
+		outdated = append(outdated, fileName)
+		signed[fileName] = h.IsSigned()
+	}
+
+	if *dryRun || len(outdated) == 0 {
+		exitWithCounts(countDone, countFail)
+	}
+
+	block, err := readKeyBlock(keys, keyprovider.Request{Prompt: "Enter key phrase"})
+	if err != nil {
+		fatalf("Key phrase error: %s", err.Error())
+	}
+
+	opts := processor.EncryptOptions{
+		HashID:  hg.ID,
+		Padding: pad,
+		Signer:  signer,
+	}
+
+	var verifyOpts processor.DecryptOptions
+	if signer != nil {
+		verifyOpts.Signer = signer.Public().(ed25519.PublicKey)
+	}
+
+	for _, fileName := range outdated {
+		if err := upgradeFile(opts, verifyOpts, block, fileName); err != nil {
+			log.Printf("%s: FAIL: %s", fileName, err.Error())
+			countFail++
+			continue
+		}
+
+		if signed[fileName] && signer == nil {
+			fmt.Printf("%s: DONE (signature removed)\n", fileName)
+		} else {
+			fmt.Printf("%s: DONE\n", fileName)
+		}
+
+		countDone++
+	}
+
+	exitWithCounts(countDone, countFail)
+}
+
+// upgradeFile writes the file in the current format to a temporary file, verifies that
+// it can be decrypted and only then replaces the original.
+func upgradeFile(opts processor.EncryptOptions, verifyOpts processor.DecryptOptions, block cipher.Block, fileName string) error {
+	return file.Replace(fileName, func(tempName string) error {
+		if err := processor.UpgradeFile(opts, block, fileName, tempName); err != nil {
+			return err
+		}
+
+		if err := processor.VerifyFile(verifyOpts, block, tempName); err != nil {
+			return fmt.Errorf("verification failed: %w", err)
+		}
+
+		return nil
+	})
+}